@@ -0,0 +1,140 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux
+
+// Command ipcctl lists, inspects and removes ipc objects created by go-ipc.
+// It understands the library's naming scheme, so objects, which consist of
+// several shared memory objects and system v objects, are shown under their logical names.
+// With -prefix, only the objects of the given namespace are shown, and their names are given without the prefix.
+// The library does not track lock owners, so for mutexes ipcctl reports whether they are locked
+// and have waiters, but not the process, which holds the lock.
+//
+// Usage:
+//	ipcctl [flags] list
+//	ipcctl [flags] inspect type name
+//	ipcctl [flags] destroy type name
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/aybabtme/go-ipc/namespace"
+)
+
+var (
+	shmDir   = flag.String("shm-dir", "/dev/shm", "shared memory directory")
+	mqDir    = flag.String("mq-dir", "/dev/mqueue", "linux message queues directory")
+	keysDir  = flag.String("keys-dir", os.TempDir(), "directory with system v key files")
+	prefix   = flag.String("prefix", "", "namespace prefix of object names")
+	force    = flag.Bool("force", false, "destroy objects, which are in use")
+	showRest = flag.Bool("all", false, "list shared memory objects, which do not belong to go-ipc objects")
+)
+
+const usage = `  ipcctl lists, inspects and removes go-ipc objects.
+available commands:
+  list
+    lists objects with their types and states
+  inspect type name
+    prints the state of the object. lock owners are not tracked, so they are not reported
+  destroy type name
+    removes the object. objects, which are in use, are not removed without -force
+available types:
+  mutex, semamutex, spin, ticket, rwmutex, event, cond, fastmq, sema, sysvmq, linuxmq, shm
+flags:
+`
+
+func list() error {
+	if flag.NArg() != 1 {
+		return fmt.Errorf("list: must not provide any arguments")
+	}
+	objects, err := listObjects()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tNAME\tSTATE")
+	for _, obj := range objects {
+		if obj.typ == typeShm && !*showRest {
+			continue
+		}
+		state, err := obj.state()
+		if err != nil {
+			state = "error: " + err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", obj.typ, obj.name, state)
+	}
+	return w.Flush()
+}
+
+func inspect() error {
+	if flag.NArg() != 3 {
+		return fmt.Errorf("inspect: must provide exactly two arguments")
+	}
+	obj, err := findObject(flag.Arg(1), flag.Arg(2))
+	if err != nil {
+		return err
+	}
+	state, err := obj.state()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("type:  %s\nname:  %s\nstate: %s\n", obj.typ, obj.name, state)
+	for _, part := range obj.parts {
+		fmt.Printf("part:  %s\n", part)
+	}
+	return nil
+}
+
+func destroy() error {
+	if flag.NArg() != 3 {
+		return fmt.Errorf("destroy: must provide exactly two arguments")
+	}
+	obj, err := findObject(flag.Arg(1), flag.Arg(2))
+	if err != nil {
+		return err
+	}
+	if !*force {
+		if reason := obj.inUse(); len(reason) > 0 {
+			return fmt.Errorf("destroy: the %s is in use (%s), use -force to destroy it anyway", obj.typ, reason)
+		}
+	}
+	return obj.destroy()
+}
+
+func runCommand() error {
+	command := flag.Arg(0)
+	switch command {
+	case "list":
+		return list()
+	case "inspect":
+		return inspect()
+	case "destroy":
+		return destroy()
+	default:
+		return fmt.Errorf("unknown command: %s", command)
+	}
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	ns := namespace.Namespace{Prefix: *prefix, ShmDir: *shmDir, KeyDir: *keysDir}
+	if err := namespace.Set(ns); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if err := runCommand(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,726 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aybabtme/go-ipc/internal/common"
+	"github.com/aybabtme/go-ipc/internal/lw"
+	"github.com/aybabtme/go-ipc/mmf"
+	"github.com/aybabtme/go-ipc/mq"
+	"github.com/aybabtme/go-ipc/shm"
+	ipc_sync "github.com/aybabtme/go-ipc/sync"
+)
+
+const (
+	typeMutex     = "mutex"
+	typeSemaMutex = "semamutex"
+	typeSpin      = "spin"
+	typeTicket    = "ticket"
+	typeRWMutex   = "rwmutex"
+	typeEvent     = "event"
+	typeCond      = "cond"
+	typeFastMq    = "fastmq"
+	typeSema      = "sema"
+	typeSysVMq    = "sysvmq"
+	typeLinuxMq   = "linuxmq"
+	typeShm       = "shm"
+)
+
+// the names below must match the ones used by the library:
+//	sync/mutex.go: mutexSharedStateName
+//	sync/mutex_spin.go: spinName
+//	sync/mutex_ticket.go: NewTicketMutex
+//	sync/event.go: eventName
+//	sync/cond_futex.go: condSharedStateName
+//	sync/rwmutex.go: makeRWMWaiters
+//	sync/sema_futex_linux.go: futexSemaName
+//	mq/mq_fast.go: fastMqStateName, fastMqLockerName, fastMqCondName
+const (
+	futexMutexSuffix  = ".sf"
+	semaMutexSuffix   = ".ss"
+	ticketSuffix      = ".stk"
+	rwMutexSuffix     = ".srw"
+	spinPrefix        = "go-ipc.spin."
+	eventSuffix       = ".ev"
+	condSuffix        = ".st"
+	semaSuffix        = ".sem"
+	rwReadersSuffix   = ".rs"
+	rwWritersSuffix   = ".ws"
+	rwUpgradeSuffix   = ".us"
+	rwUpgradersSuffix = ".ls"
+)
+
+// rwWaiterSuffixes are the suffixes of rwmutex semaphores.
+var rwWaiterSuffixes = []string{rwReadersSuffix, rwWritersSuffix, rwUpgradeSuffix, rwUpgradersSuffix}
+
+// object is a go-ipc object, which may consist of several system objects.
+type object struct {
+	typ  string
+	name string
+	// parts are shared memory objects and system v keys, the object consists of.
+	parts []string
+	// sysv is an entry from /proc/sysvipc for system v objects.
+	sysv *sysvEntry
+}
+
+type sysvEntry struct {
+	key    common.Key
+	id     int
+	nsems  int
+	qnum   int
+	cbytes int
+}
+
+func fastMqStateName(name string) string {
+	return name + condSuffix
+}
+
+func fastMqLockerStateName(name string) string {
+	return name + ".m" + futexMutexSuffix
+}
+
+func fastMqCondStateName(name, typ string) string {
+	return name + ".cv" + typ + condSuffix
+}
+
+// classifyShm groups shared memory objects into go-ipc objects.
+// Names, which do not belong to any known object, are returned as plain shm objects.
+func classifyShm(names []string) []*object {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	var result []*object
+	claim := func(typ, name string, parts ...string) {
+		for _, part := range parts {
+			delete(set, part)
+		}
+		result = append(result, &object{typ: typ, name: name, parts: parts})
+	}
+	// fast mqs go first, as they consist of a mutex and condvars.
+	for _, name := range names {
+		if !strings.HasSuffix(name, condSuffix) {
+			continue
+		}
+		base := strings.TrimSuffix(name, condSuffix)
+		sendCond, recvCond := fastMqCondStateName(base, "s"), fastMqCondStateName(base, "r")
+		if set[name] && set[sendCond] && set[recvCond] {
+			parts := []string{name, sendCond, recvCond}
+			if locker := fastMqLockerStateName(base); set[locker] {
+				parts = append(parts, locker)
+			}
+			claim(typeFastMq, base, parts...)
+		}
+	}
+	// rwmutexes go before semaphores, as futex-based waiters of an rwmutex are semaphores.
+	for _, name := range names {
+		if !set[name] || !strings.HasSuffix(name, rwMutexSuffix) {
+			continue
+		}
+		base := strings.TrimSuffix(name, rwMutexSuffix)
+		parts := []string{name}
+		for _, suffix := range rwWaiterSuffixes {
+			if waiter := base + suffix + semaSuffix; set[waiter] {
+				parts = append(parts, waiter)
+			}
+		}
+		claim(typeRWMutex, base, parts...)
+	}
+	for _, name := range names {
+		if !set[name] {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name, spinPrefix):
+			claim(typeSpin, strings.TrimPrefix(name, spinPrefix), name)
+		case strings.HasSuffix(name, futexMutexSuffix):
+			claim(typeMutex, strings.TrimSuffix(name, futexMutexSuffix), name)
+		case strings.HasSuffix(name, semaMutexSuffix):
+			claim(typeSemaMutex, strings.TrimSuffix(name, semaMutexSuffix), name)
+		case strings.HasSuffix(name, ticketSuffix):
+			claim(typeTicket, strings.TrimSuffix(name, ticketSuffix), name)
+		case strings.HasSuffix(name, eventSuffix):
+			claim(typeEvent, strings.TrimSuffix(name, eventSuffix), name)
+		case strings.HasSuffix(name, condSuffix):
+			claim(typeCond, strings.TrimSuffix(name, condSuffix), name)
+		case strings.HasSuffix(name, semaSuffix):
+			claim(typeSema, strings.TrimSuffix(name, semaSuffix), name)
+		}
+	}
+	for _, name := range names {
+		if set[name] {
+			claim(typeShm, name, name)
+		}
+	}
+	return result
+}
+
+// listObjects returns all the objects found in the system.
+func listObjects() ([]*object, error) {
+	names, err := dirNames(*shmDir)
+	if err != nil {
+		return nil, err
+	}
+	result := classifyShm(trimPrefix(names))
+	rwmutexes, semaMutexes := make(map[string]*object), make(map[string]*object)
+	for _, obj := range result {
+		switch obj.typ {
+		case typeRWMutex:
+			rwmutexes[obj.name] = obj
+		case typeSemaMutex:
+			semaMutexes[obj.name] = obj
+		}
+	}
+	keyNames, err := keyFileNames()
+	if err != nil {
+		return nil, err
+	}
+	sems, err := readSysvEntries("/proc/sysvipc/sem", 3, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range sems {
+		if !inNamespace(keyNames, entry.key) {
+			continue
+		}
+		name := sysvName(keyNames, entry.key)
+		// rwmutex waiters are semaphores.
+		if base, found := trimRWWaiterSuffix(name); found {
+			if rw, found := rwmutexes[base]; found {
+				rw.parts = append(rw.parts, name)
+				continue
+			}
+		}
+		// a sema mutex is a semaphore with the mutex's name.
+		if m, found := semaMutexes[name]; found {
+			m.parts = append(m.parts, name)
+			continue
+		}
+		result = append(result, &object{typ: typeSema, name: name, parts: []string{name}, sysv: entry})
+	}
+	msgs, err := readSysvEntries("/proc/sysvipc/msg", -1, 4, 3)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range msgs {
+		if !inNamespace(keyNames, entry.key) {
+			continue
+		}
+		name := sysvName(keyNames, entry.key)
+		result = append(result, &object{typ: typeSysVMq, name: name, parts: []string{name}, sysv: entry})
+	}
+	if names, err = dirNames(*mqDir); err != nil {
+		return nil, err
+	}
+	for _, name := range trimPrefix(names) {
+		result = append(result, &object{typ: typeLinuxMq, name: name, parts: []string{name}})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].typ != result[j].typ {
+			return result[i].typ < result[j].typ
+		}
+		return result[i].name < result[j].name
+	})
+	return result, nil
+}
+
+// findObject returns an object with the given type and name.
+func findObject(typ, name string) (*object, error) {
+	obj := &object{typ: typ, name: name}
+	switch typ {
+	case typeMutex:
+		obj.parts = []string{name + futexMutexSuffix}
+	case typeSemaMutex:
+		obj.parts = []string{name + semaMutexSuffix}
+		if _, err := findSysvObject(&object{typ: typeSema, name: name}); err == nil {
+			obj.parts = append(obj.parts, name)
+		}
+	case typeSpin:
+		obj.parts = []string{spinPrefix + name}
+	case typeTicket:
+		obj.parts = []string{name + ticketSuffix}
+	case typeRWMutex:
+		obj.parts = []string{name + rwMutexSuffix}
+		// waiters are futex-based semaphores, or system v semaphores.
+		// upgrade waiters are created on first use, so they may not exist.
+		for _, suffix := range rwWaiterSuffixes {
+			waiter := name + suffix
+			if _, err := os.Stat(nsPath(*shmDir, waiter+semaSuffix)); err == nil {
+				obj.parts = append(obj.parts, waiter+semaSuffix)
+			} else if _, err := findSysvObject(&object{typ: typeSema, name: waiter}); err == nil {
+				obj.parts = append(obj.parts, waiter)
+			}
+		}
+	case typeEvent:
+		obj.parts = []string{name + eventSuffix}
+	case typeCond:
+		obj.parts = []string{name + condSuffix}
+	case typeFastMq:
+		obj.parts = []string{fastMqStateName(name), fastMqCondStateName(name, "s"), fastMqCondStateName(name, "r"), fastMqLockerStateName(name)}
+	case typeShm:
+		obj.parts = []string{name}
+	case typeSema:
+		// futex-based semaphores are shared memory objects, others are system v semaphores.
+		if _, err := os.Stat(nsPath(*shmDir, name+semaSuffix)); err != nil {
+			return findSysvObject(obj)
+		}
+		obj.parts = []string{name + semaSuffix}
+	case typeSysVMq:
+		return findSysvObject(obj)
+	case typeLinuxMq:
+		if _, err := os.Stat(nsPath(*mqDir, name)); err != nil {
+			return nil, err
+		}
+		obj.parts = []string{name}
+		return obj, nil
+	default:
+		return nil, fmt.Errorf("unknown object type %q", typ)
+	}
+	if _, err := os.Stat(nsPath(*shmDir, obj.parts[0])); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func findSysvObject(obj *object) (*object, error) {
+	key, err := sysvKey(obj.name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a key for %q: %v", obj.name, err)
+	}
+	var entries []*sysvEntry
+	if obj.typ == typeSema {
+		entries, err = readSysvEntries("/proc/sysvipc/sem", 3, -1, -1)
+	} else {
+		entries, err = readSysvEntries("/proc/sysvipc/msg", -1, 4, 3)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.key == key {
+			obj.sysv = entry
+			obj.parts = []string{obj.name}
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("%s %q with key %d does not exist", obj.typ, obj.name, key)
+}
+
+// state returns a human-readable description of the object's state.
+func (obj *object) state() (string, error) {
+	switch obj.typ {
+	case typeMutex, typeSpin:
+		value, err := loadUint32(obj.parts[0])
+		if err != nil {
+			return "", err
+		}
+		return mutexState(value), nil
+	case typeSemaMutex:
+		value, mode, err := loadSemaMutexState(obj.parts[0])
+		if err != nil {
+			return "", err
+		}
+		if mode == lw.SemaMutexModeUndo {
+			return "SEM_UNDO mode, the lock is held in the semaphore", nil
+		}
+		return mutexState(value), nil
+	case typeTicket:
+		serving, next, err := loadTicketState(obj.parts[0])
+		if err != nil {
+			return "", err
+		}
+		return ticketState(serving, next), nil
+	case typeRWMutex:
+		value, err := loadUint64(obj.parts[0])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("readers: %d, waiting readers: %d, writer: %v, waiting writers: %d, upgrading: %v, policy: %d",
+			value&lw.RWMutexMask, (value>>lw.RWMutexWaitingReaderShift)&lw.RWMutexMask, value&lw.RWMutexWriterBit != 0,
+			(value>>lw.RWMutexWaitingWriterShift)&lw.RWMutexMask, value&lw.RWMutexUpgradingBit != 0, (value>>lw.RWMutexPolicyShift)&lw.RWMutexPolicyMask), nil
+	case typeEvent:
+		value, err := loadUint32(obj.parts[0])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("signaled: %v, waiters: %d", int32(value) < 0, value&math.MaxInt32), nil
+	case typeCond:
+		value, err := loadUint32(obj.parts[0])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("sequence: %d", value), nil
+	case typeFastMq:
+		return fastMqState(obj.name)
+	case typeSema:
+		if obj.sysv == nil {
+			value, waiters, err := loadFutexSemaState(obj.parts[0])
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("value: %d, waiters: %d", value, waiters), nil
+		}
+		return fmt.Sprintf("key: %d, id: %d, semaphores: %d", obj.sysv.key, obj.sysv.id, obj.sysv.nsems), nil
+	case typeSysVMq:
+		return fmt.Sprintf("key: %d, id: %d, messages: %d, bytes: %d", obj.sysv.key, obj.sysv.id, obj.sysv.qnum, obj.sysv.cbytes), nil
+	case typeLinuxMq:
+		data, err := ioutil.ReadFile(nsPath(*mqDir, obj.name))
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(strings.Fields(string(data)), " "), nil
+	case typeShm:
+		stat, err := os.Stat(nsPath(*shmDir, obj.name))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("size: %d", stat.Size()), nil
+	}
+	return "", fmt.Errorf("unknown object type %q", obj.typ)
+}
+
+// inUse returns a non-empty reason, if the object is used by someone.
+func (obj *object) inUse() string {
+	switch obj.typ {
+	case typeMutex, typeSpin:
+		if value, err := loadUint32(obj.parts[0]); err == nil && value != lw.MutexUnlocked {
+			return mutexState(value)
+		}
+	case typeSemaMutex:
+		if value, mode, err := loadSemaMutexState(obj.parts[0]); err == nil && mode != lw.SemaMutexModeUndo && value != lw.MutexUnlocked {
+			return mutexState(value)
+		}
+	case typeTicket:
+		if serving, next, err := loadTicketState(obj.parts[0]); err == nil && serving != next {
+			return ticketState(serving, next)
+		}
+	case typeRWMutex:
+		if value, err := loadUint64(obj.parts[0]); err == nil && value&(1<<lw.RWMutexPolicyShift-1) != 0 {
+			return "locked"
+		}
+	case typeEvent:
+		if value, err := loadUint32(obj.parts[0]); err == nil && value&math.MaxInt32 != 0 {
+			return "has waiters"
+		}
+	case typeFastMq:
+		if value, err := loadUint32(fastMqLockerStateName(obj.name)); err == nil && value != lw.MutexUnlocked {
+			return "the queue is " + mutexState(value)
+		}
+		if q, err := mq.OpenFastMq(obj.name, mq.O_NONBLOCK); err == nil {
+			defer q.Close()
+			if l := q.Len(); l > 0 {
+				return fmt.Sprintf("%d messages in the queue", l)
+			}
+		}
+	case typeSysVMq:
+		if obj.sysv.qnum > 0 {
+			return fmt.Sprintf("%d messages in the queue", obj.sysv.qnum)
+		}
+	}
+	return ""
+}
+
+// destroy removes the object with the library's functions.
+func (obj *object) destroy() error {
+	switch obj.typ {
+	case typeMutex:
+		return ipc_sync.DestroyMutex(obj.name)
+	case typeSemaMutex:
+		return ipc_sync.DestroySemaMutex(obj.name)
+	case typeSpin:
+		return ipc_sync.DestroySpinMutex(obj.name)
+	case typeTicket:
+		return ipc_sync.DestroyTicketMutex(obj.name)
+	case typeRWMutex:
+		return ipc_sync.DestroyRWMutex(obj.name)
+	case typeEvent:
+		return ipc_sync.DestroyEvent(obj.name)
+	case typeCond:
+		return ipc_sync.DestroyCond(obj.name)
+	case typeFastMq:
+		return mq.DestroyFastMq(obj.name)
+	case typeSema:
+		return ipc_sync.DestroySemaphore(obj.name)
+	case typeSysVMq:
+		return mq.DestroySystemVMessageQueue(obj.name)
+	case typeLinuxMq:
+		return mq.DestroyLinuxMessageQueue(obj.name)
+	case typeShm:
+		return shm.DestroyMemoryObject(obj.name)
+	}
+	return fmt.Errorf("unknown object type %q", obj.typ)
+}
+
+func mutexState(value uint32) string {
+	switch value {
+	case lw.MutexUnlocked:
+		return "unlocked"
+	case lw.MutexLockedNoWaiters:
+		return "locked (owner is not tracked)"
+	default:
+		return "locked, has waiters (owner is not tracked)"
+	}
+}
+
+// ticketState describes a ticket mutex. The number of waiters includes the ones, which have given up waiting,
+// but have not been skipped by the owner yet.
+func ticketState(serving, next uint32) string {
+	if serving == next {
+		return "unlocked"
+	}
+	return fmt.Sprintf("locked, serving ticket: %d, waiters: %d (owner is not tracked)", serving, next-serving-1)
+}
+
+func fastMqState(name string) (string, error) {
+	q, err := mq.OpenFastMq(name, mq.O_NONBLOCK)
+	if err != nil {
+		return "", err
+	}
+	defer q.Close()
+	lockerState := "unknown"
+	if value, err := loadUint32(fastMqLockerStateName(name)); err == nil {
+		lockerState = mutexState(value)
+	}
+	return fmt.Sprintf("messages: %d/%d, max message size: %d, lock: %s", q.Len(), q.Cap(), q.MaxMsgSize(), lockerState), nil
+}
+
+// loadUint32 atomically reads the first 4 bytes of a shared memory object.
+func loadUint32(shmName string) (uint32, error) {
+	var result uint32
+	err := withAccessor(shmName, 4, func(a *mmf.RegionAccessor) (err error) {
+		result, err = a.LoadUint32At(0)
+		return
+	})
+	return result, err
+}
+
+// loadUint64 atomically reads the first 8 bytes of a shared memory object.
+func loadUint64(shmName string) (uint64, error) {
+	var result uint64
+	err := withAccessor(shmName, 8, func(a *mmf.RegionAccessor) (err error) {
+		result, err = a.LoadUint64At(0)
+		return
+	})
+	return result, err
+}
+
+// loadTicketState atomically reads the served and the next tickets of a ticket mutex.
+func loadTicketState(shmName string) (serving, next uint32, err error) {
+	err = withAccessor(shmName, 8, func(a *mmf.RegionAccessor) (err error) {
+		if serving, err = a.LoadUint32At(0); err != nil {
+			return
+		}
+		next, err = a.LoadUint32At(4)
+		return
+	})
+	return
+}
+
+// loadSemaMutexState atomically reads the lightweight mutex state and the mode of a sema mutex.
+func loadSemaMutexState(shmName string) (value, mode uint32, err error) {
+	err = withAccessor(shmName, lw.SemaMutexStateSize, func(a *mmf.RegionAccessor) (err error) {
+		if value, err = a.LoadUint32At(0); err != nil {
+			return
+		}
+		mode, err = a.LoadUint32At(lw.SemaMutexModeOffset)
+		return
+	})
+	return
+}
+
+// loadFutexSemaState reads the value and the number of waiters of a futex-based semaphore.
+func loadFutexSemaState(shmName string) (value int32, waiters int32, err error) {
+	err = withAccessor(shmName, 12, func(a *mmf.RegionAccessor) (err error) {
+		var v, single, multi uint32
+		if v, err = a.LoadUint32At(0); err != nil {
+			return
+		}
+		if single, err = a.LoadUint32At(4); err != nil {
+			return
+		}
+		if multi, err = a.LoadUint32At(8); err != nil {
+			return
+		}
+		value, waiters = int32(v), int32(single)+int32(multi)
+		return
+	})
+	return
+}
+
+func withAccessor(shmName string, size int, f func(a *mmf.RegionAccessor) error) error {
+	obj, err := shm.NewMemoryObject(shmName, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	region, err := mmf.NewMemoryRegion(obj, mmf.MEM_READ_ONLY, 0, size)
+	if err != nil {
+		return err
+	}
+	defer region.Close()
+	return f(mmf.NewRegionAccessor(region, nil))
+}
+
+func dirNames(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var result []string
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			result = append(result, info.Name())
+		}
+	}
+	return result, nil
+}
+
+// trimPrefix returns the names, which belong to the namespace, without the namespace prefix.
+func trimPrefix(names []string) []string {
+	if len(*prefix) == 0 {
+		return names
+	}
+	var result []string
+	for _, name := range names {
+		if strings.HasPrefix(name, *prefix) {
+			result = append(result, strings.TrimPrefix(name, *prefix))
+		}
+	}
+	return result
+}
+
+// nsPath returns a path to the file for the object with the given name in the namespace.
+func nsPath(dir, name string) string {
+	return filepath.Join(dir, *prefix+name)
+}
+
+// sysvKey returns a key for the object with the given name.
+// The key registry is checked first, then the key file.
+func sysvKey(name string) (common.Key, error) {
+	registered, err := common.RegisteredKeys()
+	if err != nil {
+		return 0, err
+	}
+	if key, found := registered[*prefix+name]; found {
+		return key, nil
+	}
+	return common.FileKey(nsPath(*keysDir, name))
+}
+
+// keyFileNames returns names of the files, which may be used as system v keys, by their keys.
+// Key files are empty files created by common.KeyForName.
+// The names from the key registry are also returned.
+func keyFileNames() (map[common.Key][]string, error) {
+	infos, err := ioutil.ReadDir(*keysDir)
+	if err != nil {
+		return nil, err
+	}
+	registered, err := common.RegisteredKeys()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[common.Key][]string)
+	for name, key := range registered {
+		if strings.HasPrefix(name, *prefix) {
+			result[key] = append(result[key], strings.TrimPrefix(name, *prefix))
+		}
+	}
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || info.Size() != 0 || !strings.HasPrefix(info.Name(), *prefix) {
+			continue
+		}
+		if key, err := common.FileKey(filepath.Join(*keysDir, info.Name())); err == nil {
+			result[key] = append(result[key], strings.TrimPrefix(info.Name(), *prefix))
+		}
+	}
+	return result, nil
+}
+
+// inNamespace returns true, if a system v object with the given key may belong to the namespace.
+// Without a prefix all objects are considered to be in the namespace.
+func inNamespace(keyNames map[common.Key][]string, key common.Key) bool {
+	_, found := keyNames[key]
+	return found || len(*prefix) == 0
+}
+
+// sysvName returns a name for the given key. As different files may have the same key,
+// the name may be ambiguous.
+func sysvName(keyNames map[common.Key][]string, key common.Key) string {
+	names := keyNames[key]
+	switch len(names) {
+	case 0:
+		return fmt.Sprintf("<key %d>", key)
+	case 1:
+		return names[0]
+	default:
+		return strings.Join(names, "|")
+	}
+}
+
+// trimRWWaiterSuffix returns the name of the rwmutex, if the given name is the name of one of its semaphores.
+func trimRWWaiterSuffix(name string) (string, bool) {
+	for _, suffix := range rwWaiterSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix), true
+		}
+	}
+	return name, false
+}
+
+// readSysvEntries parses a file from /proc/sysvipc.
+// The first two columns are always the key and the id, other columns are given by their indices.
+// A negative index means, that the column is not needed.
+func readSysvEntries(path string, nsemsCol, qnumCol, cbytesCol int) ([]*sysvEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	var result []*sysvEntry
+	scanner := bufio.NewScanner(file)
+	// skip the header.
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		column := func(idx int) int {
+			if idx < 0 || idx >= len(fields) {
+				return 0
+			}
+			value, _ := strconv.Atoi(fields[idx])
+			return value
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		key, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		result = append(result, &sysvEntry{
+			key:    common.Key(uint32(key)),
+			id:     column(1),
+			nsems:  column(nsemsCol),
+			qnum:   column(qnumCol),
+			cbytes: column(cbytesCol),
+		})
+	}
+	return result, scanner.Err()
+}
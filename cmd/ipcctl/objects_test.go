@@ -0,0 +1,67 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyShm(t *testing.T) {
+	a := assert.New(t)
+	names := []string{
+		"q.st", "q.cvs.st", "q.cvr.st", "q.m.sf",
+		"go-ipc.spin.s",
+		"rw.srw",
+		"m.sf",
+		"sm-mutex.ss",
+		"t.stk",
+		"e.ev",
+		"c.st",
+		"sm.sem",
+		"other",
+	}
+	objects := classifyShm(names)
+	types := make(map[string]string)
+	for _, obj := range objects {
+		types[obj.name] = obj.typ
+	}
+	a.Equal(map[string]string{
+		"q":        typeFastMq,
+		"s":        typeSpin,
+		"rw":       typeRWMutex,
+		"m":        typeMutex,
+		"sm-mutex": typeSemaMutex,
+		"t":        typeTicket,
+		"e":        typeEvent,
+		"c":        typeCond,
+		"sm":       typeSema,
+		"other":    typeShm,
+	}, types)
+	a.Equal([]string{"q.st", "q.cvs.st", "q.cvr.st", "q.m.sf"}, objects[0].parts)
+}
+
+func TestClassifyShmRWMutexWaiters(t *testing.T) {
+	a := assert.New(t)
+	objects := classifyShm([]string{"rw.rs.sem", "rw.srw", "rw.ws.sem", "rw.us.sem", "sm.sem"})
+	if a.Len(objects, 2) {
+		a.Equal(typeRWMutex, objects[0].typ)
+		a.Equal("rw", objects[0].name)
+		a.Equal([]string{"rw.srw", "rw.rs.sem", "rw.ws.sem", "rw.us.sem"}, objects[0].parts)
+		a.Equal(typeSema, objects[1].typ)
+		a.Equal("sm", objects[1].name)
+	}
+}
+
+func TestClassifyShmCondWithoutMq(t *testing.T) {
+	objects := classifyShm([]string{"q.st", "q.cvs.st"})
+	if assert.Len(t, objects, 2) {
+		assert.Equal(t, typeCond, objects[0].typ)
+		assert.Equal(t, "q", objects[0].name)
+		assert.Equal(t, typeCond, objects[1].typ)
+		assert.Equal(t, "q.cvs", objects[1].name)
+	}
+}
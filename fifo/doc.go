@@ -4,4 +4,5 @@
 // It gives access to OS-native FIFO objects via:
 //	CreateNamedPipe on windows
 //	Mkfifo on unix
+// On unix, Listen and Dial provide duplex connections built on top of FIFO pairs.
 package fifo
@@ -0,0 +1,203 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build darwin freebsd linux
+
+package fifo
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	testListenerName = "go-fifo-listener-test"
+)
+
+func TestFifoConnEcho(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(Destroy(testListenerName))
+	l, err := Listen(testListenerName, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(l.Close())
+	}()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				io.Copy(conn, conn)
+				conn.Close()
+			}(conn)
+		}
+	}()
+	const clients = 8
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(seed byte) {
+			defer wg.Done()
+			conn, err := DialTimeout(testListenerName, time.Second*5)
+			if !a.NoError(err) {
+				return
+			}
+			data := make([]byte, 4096)
+			for i := range data {
+				data[i] = byte(i) + seed
+			}
+			go func() {
+				_, err := conn.Write(data)
+				a.NoError(err)
+			}()
+			received := make([]byte, len(data))
+			_, err = io.ReadFull(conn, received)
+			a.NoError(err)
+			a.Equal(data, received)
+			a.NoError(conn.Close())
+		}(byte(i))
+	}
+	wg.Wait()
+}
+
+func TestFifoConnDeadline(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(Destroy(testListenerName))
+	l, err := Listen(testListenerName, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(l.Close())
+	}()
+	a.NoError(l.SetDeadline(time.Now().Add(time.Millisecond * 50)))
+	_, err = l.Accept()
+	a.Error(err)
+	a.NoError(l.SetDeadline(time.Time{}))
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if a.NoError(err) {
+			accepted <- conn
+		}
+	}()
+	conn, err := Dial(testListenerName)
+	if !a.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	server := <-accepted
+	defer server.Close()
+	a.Equal(Addr(testListenerName), conn.RemoteAddr())
+	a.Equal(Addr(testListenerName), server.LocalAddr())
+	a.NoError(conn.SetReadDeadline(time.Now().Add(time.Millisecond * 50)))
+	start := time.Now()
+	_, err = conn.Read(make([]byte, 1))
+	a.True(os.IsTimeout(err))
+	a.True(time.Since(start) >= time.Millisecond*50)
+	a.NoError(server.Close())
+	a.NoError(conn.SetReadDeadline(time.Time{}))
+	_, err = conn.Read(make([]byte, 1))
+	a.Equal(io.EOF, err)
+}
+
+func TestFifoDialNoListener(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(Destroy(testListenerName))
+	_, err := DialTimeout(testListenerName, time.Millisecond*100)
+	a.Error(err)
+}
+
+func TestFifoConnAcceptError(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(Destroy(testListenerName))
+	l, err := Listen(testListenerName, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(l.Close())
+	}()
+	// connection fifos for this request do not exist.
+	a.NoError(sendConnRequest(testListenerName, "0.1"))
+	_, err = l.Accept()
+	if a.Error(err) {
+		netErr, ok := err.(net.Error)
+		if a.True(ok) {
+			a.True(netErr.Temporary())
+		}
+	}
+}
+
+func TestFifoConnStalledClient(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(Destroy(testListenerName))
+	l, err := Listen(testListenerName, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(l.Close())
+	}()
+	// the stalled client creates its fifos and sends a request, but never completes the handshake.
+	clientToServer, serverToClient := connFifoPaths(testListenerName, "0.2")
+	for _, path := range []string{clientToServer, serverToClient} {
+		if !a.NoError(unix.Mkfifo(path, 0600)) {
+			return
+		}
+		defer os.Remove(path)
+	}
+	r, dummy, err := openReadEnd(serverToClient)
+	if !a.NoError(err) {
+		return
+	}
+	defer r.Close()
+	defer dummy.Close()
+	a.NoError(sendConnRequest(testListenerName, "0.2"))
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if a.NoError(err) {
+			accepted <- conn
+		}
+	}()
+	start := time.Now()
+	conn, err := DialTimeout(testListenerName, DefaultHandshakeTimeout/2)
+	if !a.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	server := <-accepted
+	defer server.Close()
+	a.True(time.Since(start) < DefaultHandshakeTimeout/2)
+	a.Equal(conn.LocalAddr(), server.RemoteAddr())
+}
+
+func TestFifoConnInvalidID(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(Destroy(testListenerName))
+	l, err := Listen(testListenerName, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(l.Close())
+	}()
+	for _, id := range []string{"../x.1", "1/2.3", "1.2.3", "1", "a.b", ""} {
+		a.NoError(sendConnRequest(testListenerName, id))
+		_, err = l.Accept()
+		if a.Error(err) {
+			a.Contains(err.Error(), "invalid connection id")
+		}
+	}
+}
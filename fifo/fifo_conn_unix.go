@@ -0,0 +1,453 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build darwin freebsd linux
+
+package fifo
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// DefaultHandshakeTimeout is the time Listener.Accept waits for a client to finish the handshake.
+	DefaultHandshakeTimeout = 5 * time.Second
+
+	// connection request size. it must not exceed PIPE_BUF for the write to be atomic.
+	connRequestSize = 64
+	handshakeByte   = byte(1)
+)
+
+var (
+	connCounter uint32
+)
+
+// this is to ensure, that fifo connections satisfy net interfaces.
+var (
+	_ net.Conn     = (*Conn)(nil)
+	_ net.Listener = (*Listener)(nil)
+	_ net.Addr     = Addr("")
+)
+
+// Addr is the name of a fifo listener.
+type Addr string
+
+// Network returns "fifo".
+func (a Addr) Network() string {
+	return "fifo"
+}
+
+func (a Addr) String() string {
+	return string(a)
+}
+
+// Conn is a duplex connection built on top of a pair of FIFOs.
+// It is created by Dial or Listener.Accept.
+// Both FIFOs are removed as soon as the connection is established,
+// so they do not outlive the processes using them.
+type Conn struct {
+	r, w   *os.File
+	local  Addr
+	remote Addr
+}
+
+// Read reads data from the connection.
+func (c *Conn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// Write writes data to the connection.
+func (c *Conn) Write(b []byte) (int, error) {
+	return c.w.Write(b)
+}
+
+// Close closes both FIFOs of the connection.
+// The other side will receive io.EOF after reading all the data.
+func (c *Conn) Close() error {
+	errR, errW := c.r.Close(), c.w.Close()
+	if errR != nil {
+		return errors.Wrap(errR, "failed to close read fifo")
+	}
+	if errW != nil {
+		return errors.Wrap(errW, "failed to close write fifo")
+	}
+	return nil
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline sets the read and write deadlines associated with the connection.
+func (c *Conn) SetDeadline(t time.Time) error {
+	if err := c.r.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.w.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the deadline for future Read calls.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.r.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for future Write calls.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.w.SetWriteDeadline(t)
+}
+
+// Listener accepts fifo connections.
+// Clients send their connection requests into a rendezvous FIFO with the listener's name.
+// Handshakes with clients are performed concurrently, so a stalled client does not delay the others.
+type Listener struct {
+	name  string
+	file  *os.File
+	dummy *os.File
+
+	results   chan acceptResult
+	done      chan struct{}
+	failed    chan struct{}
+	readErr   error
+	closeOnce sync.Once
+
+	mut             sync.Mutex
+	deadline        time.Time
+	deadlineChanged chan struct{}
+}
+
+type acceptResult struct {
+	conn *Conn
+	err  error
+}
+
+// handshakeError is returned by Listener.Accept, when a client failed to connect.
+// It is temporary, so the listener can be used further.
+type handshakeError struct {
+	id    string
+	inner error
+}
+
+func (e *handshakeError) Error() string {
+	return fmt.Sprintf("failed to accept client %q: %v", e.id, e.inner)
+}
+
+func (e *handshakeError) Timeout() bool {
+	return os.IsTimeout(errors.Cause(e.inner))
+}
+
+func (e *handshakeError) Temporary() bool {
+	return true
+}
+
+type acceptTimeoutError struct{}
+
+func (acceptTimeoutError) Error() string   { return "accept timed out" }
+func (acceptTimeoutError) Timeout() bool   { return true }
+func (acceptTimeoutError) Temporary() bool { return true }
+
+// Listen creates a rendezvous FIFO with the given name and returns a listener on it.
+// If the FIFO already exists, Listen fails. A FIFO left by a crashed listener can be removed with Destroy.
+//	name - listener name. Clients pass it to Dial.
+//	perm - permissions for the rendezvous FIFO. Clients create connection FIFOs with the same permissions.
+func Listen(name string, perm os.FileMode) (*Listener, error) {
+	path := fifoPath(name)
+	if err := unix.Mkfifo(path, uint32(perm)); err != nil {
+		return nil, errors.Wrap(os.NewSyscallError("mkfifo", err), "failed to create rendezvous fifo")
+	}
+	r, dummy, err := openReadEnd(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, errors.Wrap(err, "failed to open rendezvous fifo")
+	}
+	l := &Listener{
+		name:            name,
+		file:            r,
+		dummy:           dummy,
+		results:         make(chan acceptResult),
+		done:            make(chan struct{}),
+		failed:          make(chan struct{}),
+		deadlineChanged: make(chan struct{}),
+	}
+	go l.readRequests()
+	return l, nil
+}
+
+// Accept waits for and returns the next connection.
+// If a client failed to complete the handshake within DefaultHandshakeTimeout,
+// Accept returns a temporary net.Error, and the listener can be used further.
+func (l *Listener) Accept() (net.Conn, error) {
+	for {
+		l.mut.Lock()
+		deadline, changed := l.deadline, l.deadlineChanged
+		l.mut.Unlock()
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			timer := time.NewTimer(time.Until(deadline))
+			defer timer.Stop()
+			expired = timer.C
+		}
+		select {
+		case result := <-l.results:
+			if result.err != nil {
+				return nil, result.err
+			}
+			return result.conn, nil
+		case <-expired:
+			return nil, acceptTimeoutError{}
+		case <-changed:
+		case <-l.failed:
+			return nil, errors.Wrap(l.readErr, "failed to read connection request")
+		case <-l.done:
+			return nil, errors.New("the listener is closed")
+		}
+	}
+}
+
+// readRequests reads connection requests and starts a handshake for each of them.
+func (l *Listener) readRequests() {
+	request := make([]byte, connRequestSize)
+	for {
+		if _, err := io.ReadFull(l.file, request); err != nil {
+			l.readErr = err
+			close(l.failed)
+			return
+		}
+		go l.handshake(strings.TrimRight(string(request), "\x00"))
+	}
+}
+
+func (l *Listener) handshake(id string) {
+	var result acceptResult
+	if conn, err := l.accept(id); err != nil {
+		result.err = &handshakeError{id: id, inner: err}
+	} else {
+		result.conn = conn
+	}
+	select {
+	case l.results <- result:
+	case <-l.done:
+		if result.conn != nil {
+			result.conn.Close()
+		}
+	}
+}
+
+func (l *Listener) accept(id string) (*Conn, error) {
+	if !isValidConnID(id) {
+		return nil, errors.New("invalid connection id")
+	}
+	clientToServer, serverToClient := connFifoPaths(l.name, id)
+	r, dummy, err := openReadEnd(clientToServer)
+	if err != nil {
+		return nil, err
+	}
+	conn := &Conn{r: r, local: Addr(l.name), remote: Addr(l.name + "." + id)}
+	defer func() {
+		dummy.Close()
+		if err != nil {
+			r.Close()
+			if conn.w != nil {
+				conn.w.Close()
+			}
+		}
+	}()
+	if conn.w, err = os.OpenFile(serverToClient, os.O_WRONLY|syscall.O_NONBLOCK, 0); err != nil {
+		return nil, err
+	}
+	if err = writeHandshake(conn.w); err != nil {
+		return nil, err
+	}
+	if err = readHandshake(conn.r, time.Now().Add(DefaultHandshakeTimeout)); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Close closes the listener and removes the rendezvous FIFO.
+// Established connections are not affected.
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	errDummy, errFile := l.dummy.Close(), l.file.Close()
+	if err := DestroyUnixFIFO(l.name); err != nil {
+		return err
+	}
+	if errFile != nil {
+		return errors.Wrap(errFile, "failed to close rendezvous fifo")
+	}
+	return errDummy
+}
+
+// Addr returns the listener's name.
+func (l *Listener) Addr() net.Addr {
+	return Addr(l.name)
+}
+
+// SetDeadline sets the deadline for Accept calls, including the pending ones.
+// Zero value means no deadline.
+func (l *Listener) SetDeadline(t time.Time) error {
+	l.mut.Lock()
+	l.deadline = t
+	close(l.deadlineChanged)
+	l.deadlineChanged = make(chan struct{})
+	l.mut.Unlock()
+	return nil
+}
+
+// Dial connects to the listener with the given name.
+func Dial(name string) (*Conn, error) {
+	return DialTimeout(name, -1)
+}
+
+// DialTimeout connects to the listener with the given name, waiting for not longer, than the timeout.
+// Negative timeout means no timeout.
+func DialTimeout(name string, timeout time.Duration) (*Conn, error) {
+	var deadline time.Time
+	if timeout >= 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	// connection fifos are created with the same permissions, as the rendezvous one.
+	info, err := os.Stat(fifoPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Errorf("connection refused: no listener on %q", name)
+		}
+		return nil, errors.Wrap(err, "failed to stat rendezvous fifo")
+	}
+	perm := uint32(info.Mode().Perm())
+	id := fmt.Sprintf("%d.%d", os.Getpid(), atomic.AddUint32(&connCounter, 1))
+	clientToServer, serverToClient := connFifoPaths(name, id)
+	if err := unix.Mkfifo(clientToServer, perm); err != nil {
+		return nil, errors.Wrap(os.NewSyscallError("mkfifo", err), "failed to create connection fifo")
+	}
+	defer os.Remove(clientToServer)
+	if err := unix.Mkfifo(serverToClient, perm); err != nil {
+		return nil, errors.Wrap(os.NewSyscallError("mkfifo", err), "failed to create connection fifo")
+	}
+	defer os.Remove(serverToClient)
+	r, dummy, err := openReadEnd(serverToClient)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open connection fifo")
+	}
+	conn := &Conn{r: r, local: Addr(name + "." + id), remote: Addr(name)}
+	defer func() {
+		dummy.Close()
+		if err != nil {
+			r.Close()
+			if conn.w != nil {
+				conn.w.Close()
+			}
+		}
+	}()
+	if err = sendConnRequest(name, id); err != nil {
+		return nil, err
+	}
+	// the server opens its read end before writing the handshake byte,
+	// so after the byte has been received, the write end can be opened without blocking.
+	if err = readHandshake(r, deadline); err != nil {
+		return nil, err
+	}
+	if conn.w, err = os.OpenFile(clientToServer, os.O_WRONLY|syscall.O_NONBLOCK, 0); err != nil {
+		return nil, errors.Wrap(err, "failed to open connection fifo")
+	}
+	if err = writeHandshake(conn.w); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func sendConnRequest(name, id string) error {
+	if len(id) > connRequestSize {
+		return errors.New("connection id is too long")
+	}
+	file, err := os.OpenFile(fifoPath(name), os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.ENXIO {
+			return errors.Errorf("connection refused: no listener on %q", name)
+		}
+		return errors.Wrap(err, "failed to open rendezvous fifo")
+	}
+	defer file.Close()
+	request := make([]byte, connRequestSize)
+	copy(request, id)
+	if _, err = file.Write(request); err != nil {
+		return errors.Wrap(err, "failed to send connection request")
+	}
+	return nil
+}
+
+// writeHandshake tells the other side, that the write end has been opened.
+func writeHandshake(w *os.File) error {
+	if _, err := w.Write([]byte{handshakeByte}); err != nil {
+		return errors.Wrap(err, "handshake failed")
+	}
+	return nil
+}
+
+// readHandshake waits for the other side to open its write end.
+func readHandshake(r *os.File, deadline time.Time) error {
+	if err := r.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return errors.Wrap(err, "handshake failed")
+	}
+	if b[0] != handshakeByte {
+		return errors.New("handshake failed: unexpected data")
+	}
+	return r.SetReadDeadline(time.Time{})
+}
+
+// openReadEnd opens a FIFO for reading along with a dummy writer,
+// so that reads block instead of returning io.EOF until the real writer connects.
+func openReadEnd(path string) (*os.File, *os.File, error) {
+	r, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	dummy, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		r.Close()
+		return nil, nil, err
+	}
+	return r, dummy, nil
+}
+
+// isValidConnID returns true, if the id has '<pid>.<counter>' format, which Dial uses.
+// Ids from other writers of the rendezvous FIFO must not be turned into arbitrary paths.
+func isValidConnID(id string) bool {
+	parts := strings.Split(id, ".")
+	if len(parts) != 2 {
+		return false
+	}
+	for _, part := range parts {
+		if _, err := strconv.ParseUint(part, 10, 32); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func connFifoPaths(name, id string) (string, string) {
+	prefix := fifoPath(name + "." + id)
+	return prefix + ".c2s", prefix + ".s2c"
+}
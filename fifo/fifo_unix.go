@@ -6,14 +6,20 @@ package fifo
 
 import (
 	"os"
+	"syscall"
+	"time"
 
 	"github.com/aybabtme/go-ipc/internal/common"
+	"github.com/aybabtme/go-ipc/namespace"
 
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
 )
 
 // UnixFifo is a first-in-first-out unix ipc mechanism.
+// The FIFO is used in non-blocking mode under the hood, so that reads and writes
+// are managed by the runtime poller. They wait without occupying an OS thread,
+// and can be interrupted with deadlines.
 type UnixFifo struct {
 	file *os.File
 }
@@ -36,7 +42,7 @@ func NewUnixFifo(name string, flag int, perm os.FileMode) (*UnixFifo, error) {
 			err = unix.Mkfifo(path, uint32(perm))
 		}
 		if err == nil {
-			file, err = os.OpenFile(path, common.FlagsForAccess(flag), perm)
+			file, err = openFifoFile(path, flag)
 		}
 		return err
 	}
@@ -56,6 +62,29 @@ func (f *UnixFifo) Write(b []byte) (n int, err error) {
 	return f.file.Write(b)
 }
 
+// SetDeadline sets the read and write deadlines for the FIFO.
+// A zero value for t means I/O operations will not time out.
+func (f *UnixFifo) SetDeadline(t time.Time) error {
+	return f.file.SetDeadline(t)
+}
+
+// SetReadDeadline sets the deadline for future Read calls.
+// A zero value for t means Read will not time out.
+func (f *UnixFifo) SetReadDeadline(t time.Time) error {
+	return f.file.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for future Write calls.
+// A zero value for t means Write will not time out.
+func (f *UnixFifo) SetWriteDeadline(t time.Time) error {
+	return f.file.SetWriteDeadline(t)
+}
+
+// SyscallConn returns a raw file descriptor of the FIFO.
+func (f *UnixFifo) SyscallConn() (syscall.RawConn, error) {
+	return f.file.SyscallConn()
+}
+
 // Close closes the object.
 func (f *UnixFifo) Close() error {
 	return f.file.Close()
@@ -85,6 +114,26 @@ func DestroyUnixFIFO(name string) error {
 	return errors.Wrap(err, "remove failed")
 }
 
+// openFifoFile opens a FIFO and puts it into non-blocking mode.
+// The open itself blocks unless O_NONBLOCK is set in flag.
+// os.NewFile registers non-blocking descriptors in the runtime poller.
+func openFifoFile(path string, flag int) (*os.File, error) {
+	var fd int
+	err := common.UninterruptedSyscall(func() error {
+		var err error
+		fd, err = unix.Open(path, common.FlagsForAccess(flag)|unix.O_CLOEXEC, 0)
+		return os.NewSyscallError("open", err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("fcntl", err)
+	}
+	return os.NewFile(uintptr(fd), path), nil
+}
+
 func fifoPath(name string) string {
-	return "/tmp/" + name
+	return namespace.Dir(namespace.Current().FifoDir, "/tmp") + "/" + namespace.Name(name)
 }
@@ -0,0 +1,63 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build darwin freebsd linux
+
+package fifo
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnixFifoReadDeadline(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroyUnixFIFO(testFifoName)) {
+		return
+	}
+	defer DestroyUnixFIFO(testFifoName)
+	reader, err := NewUnixFifo(testFifoName, os.O_CREATE|os.O_RDONLY|O_NONBLOCK, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	defer reader.Close()
+	writer, err := NewUnixFifo(testFifoName, os.O_WRONLY, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	defer writer.Close()
+	a.NoError(reader.SetReadDeadline(time.Now().Add(time.Millisecond * 50)))
+	start := time.Now()
+	_, err = reader.Read(make([]byte, 1))
+	a.True(os.IsTimeout(err))
+	a.True(time.Since(start) >= time.Millisecond*50)
+	a.NoError(reader.SetDeadline(time.Time{}))
+	_, err = writer.Write(testData)
+	a.NoError(err)
+	buff := make([]byte, len(testData))
+	n, err := reader.Read(buff)
+	a.NoError(err)
+	a.Equal(testData[:n], buff[:n])
+}
+
+func TestUnixFifoSyscallConn(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroyUnixFIFO(testFifoName)) {
+		return
+	}
+	defer DestroyUnixFIFO(testFifoName)
+	fifo, err := NewUnixFifo(testFifoName, os.O_CREATE|os.O_RDONLY|O_NONBLOCK, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	defer fifo.Close()
+	conn, err := fifo.SyscallConn()
+	if !a.NoError(err) {
+		return
+	}
+	var fd uintptr
+	a.NoError(conn.Control(func(f uintptr) { fd = f }))
+	a.NotZero(fd)
+}
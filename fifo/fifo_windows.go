@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/aybabtme/go-ipc/internal/common"
+	"github.com/aybabtme/go-ipc/namespace"
 
 	"github.com/pkg/errors"
 	"golang.org/x/sys/windows"
@@ -82,7 +83,7 @@ func DestroyNamedPipe(name string) error {
 
 func namedPipePath(name string) string {
 	const prefix = `\\.\pipe\`
-	return prefix + name
+	return prefix + namespace.Name(name)
 }
 
 func createFifoClient(path string, flag int) (windows.Handle, error) {
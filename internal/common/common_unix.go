@@ -10,6 +10,8 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/aybabtme/go-ipc/namespace"
+
 	"golang.org/x/sys/unix"
 )
 
@@ -34,8 +36,16 @@ const (
 // Key is an unsigned integer value considered to be unique for a unique name.
 type Key uint64
 
-// KeyForName generates a key for given path.
+// KeyForName generates a key for the given name, using the key strategy of the current namespace.
+// With namespace.KeyHash strategy, the key is looked up in the key registry. If the name
+// has not been registered, the hash of the name is returned, unless it is registered for another name,
+// in which case an error satisfying os.IsNotExist is returned.
+// Objects must be created with OpenOrCreateForName, which registers the keys.
+// The resources allocated for the key must be freed with ReleaseKey, when the object is destroyed.
 func KeyForName(name string) (Key, error) {
+	if namespace.Current().KeyStrategy == namespace.KeyHash {
+		return hashKeyForName(name)
+	}
 	name = TmpFilename(name)
 	file, err := os.Create(name)
 	if err != nil {
@@ -50,9 +60,36 @@ func KeyForName(name string) (Key, error) {
 	return k, nil
 }
 
+// OpenOrCreateForName opens or creates a system v object for the given name the same way OpenOrCreate does.
+//	creator - a function, which opens or creates the object with the given key. See OpenOrCreate.
+//	flag - the combination of open flags from os package.
+// With namespace.KeyHash strategy, a key is registered only, if the object has been opened or created.
+// If the key, computed for the name, is registered for another name, the next keys are probed.
+// It returns the key of the object and a flag, telling, whether it has been created.
+func OpenOrCreateForName(name string, creator func(k Key, create bool) error, flag int) (Key, bool, error) {
+	if namespace.Current().KeyStrategy == namespace.KeyHash {
+		return openOrCreateHashKey(name, creator, flag)
+	}
+	k, err := KeyForName(name)
+	if err != nil {
+		return 0, false, err
+	}
+	created, err := OpenOrCreate(func(create bool) error {
+		return creator(k, create)
+	}, flag)
+	return k, created, err
+}
+
+// FileKey returns a key for an existing file. Unlike KeyForName, it does not create the file.
+func FileKey(path string) (Key, error) {
+	return ftok(path)
+}
+
 // TmpFilename returns a full path for a temporary file with the given name.
+// The file is placed into the key directory of the current namespace, and its name is prefixed accordingly.
 func TmpFilename(name string) string {
-	return os.TempDir() + "/" + name
+	ns := namespace.Current()
+	return namespace.Dir(ns.KeyDir, os.TempDir()) + "/" + ns.Prefix + name
 }
 
 // AbsTimeoutToTimeSpec converts given timeout value to absulute value of unix.Timespec.
@@ -0,0 +1,241 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build darwin freebsd linux
+
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aybabtme/go-ipc/namespace"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	keyRegistryName = "go-ipc.keys"
+	maxHashKey      = Key(0x7FFFFFFF)
+	maxKeyProbes    = 64
+)
+
+// keyEntry is a line of the key registry.
+type keyEntry struct {
+	key  Key
+	name string
+}
+
+// KeyRegistryPath returns the path to the registry file used by namespace.KeyHash strategy.
+func KeyRegistryPath() string {
+	return namespace.Dir(namespace.Current().KeyDir, os.TempDir()) + "/" + keyRegistryName
+}
+
+// RegisteredKeys returns the contents of the key registry of the current namespace.
+// Names are returned with the namespace prefix.
+func RegisteredKeys() (map[string]Key, error) {
+	file, err := os.Open(KeyRegistryPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	if err = unix.Flock(int(file.Fd()), unix.LOCK_SH); err != nil {
+		return nil, fmt.Errorf("locking key registry: %v", err)
+	}
+	entries, err := readKeyEntries(file)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]Key, len(entries))
+	for _, entry := range entries {
+		result[entry.name] = entry.key
+	}
+	return result, nil
+}
+
+// ReleaseKey releases the resources associated with the key of the given name:
+// removes the key file, or removes the name from the key registry.
+// It must be called, when the object is destroyed.
+func ReleaseKey(name string) error {
+	if namespace.Current().KeyStrategy == namespace.KeyHash {
+		return releaseHashKey(name)
+	}
+	if err := os.Remove(TmpFilename(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing key file: %v", err)
+	}
+	return nil
+}
+
+// hashKey returns the initial key for the name, which is its fnv-1a hash, mapped to [1, maxHashKey].
+func hashKey(name string) Key {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return nextHashKey(Key(h.Sum32()))
+}
+
+// nextHashKey returns the key, which follows the given one in the range [1, maxHashKey].
+// 0 is skipped, as it is IPC_PRIVATE.
+func nextHashKey(key Key) Key {
+	return key%maxHashKey + 1
+}
+
+// hashKeyForName returns a registered key for the name.
+// If the name is not registered, its hash is returned, unless the hash is registered for another name,
+// as the object may have been created by a process, which uses another key registry.
+func hashKeyForName(name string) (Key, error) {
+	registered, err := RegisteredKeys()
+	if err != nil {
+		return 0, err
+	}
+	name = namespace.Name(name)
+	if key, found := registered[name]; found {
+		return key, nil
+	}
+	result := hashKey(name)
+	for _, key := range registered {
+		if key == result {
+			return 0, &os.PathError{Op: "key lookup", Path: name, Err: os.ErrNotExist}
+		}
+	}
+	return result, nil
+}
+
+// openOrCreateHashKey opens or creates an object holding an exclusive lock on the key registry.
+// If the name is not registered, and its hash is not registered for another name, the object
+// with the hash key is opened or created, as it may have been created by a process, which uses
+// another key registry. Otherwise, there is a collision, and the keys following the hash are probed
+// by creating the object with the creator, until a key, which is neither registered,
+// nor used by the kernel, is found. The key is registered, if the object has been opened or created.
+func openOrCreateHashKey(name string, creator func(k Key, create bool) error, flag int) (Key, bool, error) {
+	name = namespace.Name(name)
+	if strings.Contains(name, "\n") {
+		return 0, false, fmt.Errorf("invalid object name %q", name)
+	}
+	var result Key
+	var created bool
+	var createErr error
+	err := withKeyRegistry(func(entries []keyEntry) ([]keyEntry, bool) {
+		used := make(map[Key]bool, len(entries))
+		for _, entry := range entries {
+			if entry.name == name {
+				result = entry.key
+				created, createErr = OpenOrCreate(func(create bool) error {
+					return creator(result, create)
+				}, flag)
+				return entries, false
+			}
+			used[entry.key] = true
+		}
+		result = hashKey(name)
+		if !used[result] {
+			created, createErr = OpenOrCreate(func(create bool) error {
+				return creator(result, create)
+			}, flag)
+			if createErr != nil {
+				return entries, false
+			}
+			return append(entries, keyEntry{key: result, name: name}), true
+		}
+		if FlagsForOpen(flag)&os.O_CREATE == 0 {
+			createErr = &os.PathError{Op: "key lookup", Path: name, Err: os.ErrNotExist}
+			return entries, false
+		}
+		// the hash is registered for another name, so the following keys are probed.
+		for probe := 0; ; result = nextHashKey(result) {
+			if used[result] {
+				continue
+			}
+			// the key may be used by an object, which is not in the registry.
+			if createErr = creator(result, true); !os.IsExist(createErr) {
+				break
+			}
+			if probe++; probe == maxKeyProbes {
+				createErr = fmt.Errorf("failed to find a free key for %q in %d attempts", name, maxKeyProbes)
+				break
+			}
+		}
+		if createErr != nil {
+			return entries, false
+		}
+		created = true
+		return append(entries, keyEntry{key: result, name: name}), true
+	})
+	if err == nil {
+		err = createErr
+	}
+	return result, created, err
+}
+
+func releaseHashKey(name string) error {
+	name = namespace.Name(name)
+	return withKeyRegistry(func(entries []keyEntry) ([]keyEntry, bool) {
+		for i, entry := range entries {
+			if entry.name == name {
+				return append(entries[:i], entries[i+1:]...), true
+			}
+		}
+		return entries, false
+	})
+}
+
+// withKeyRegistry calls f for the entries of the key registry, holding an exclusive lock on it.
+// If f reports, that the entries were modified, they are written back.
+func withKeyRegistry(f func(entries []keyEntry) ([]keyEntry, bool)) error {
+	file, err := os.OpenFile(KeyRegistryPath(), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return fmt.Errorf("opening key registry: %v", err)
+	}
+	defer file.Close()
+	if err = unix.Flock(int(file.Fd()), unix.LOCK_EX); err != nil {
+		return fmt.Errorf("locking key registry: %v", err)
+	}
+	entries, err := readKeyEntries(file)
+	if err != nil {
+		return err
+	}
+	entries, modified := f(entries)
+	if !modified {
+		return nil
+	}
+	if err = file.Truncate(0); err != nil {
+		return fmt.Errorf("truncating key registry: %v", err)
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking key registry: %v", err)
+	}
+	w := bufio.NewWriter(file)
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%d %s\n", entry.key, entry.name)
+	}
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("writing key registry: %v", err)
+	}
+	return nil
+}
+
+func readKeyEntries(r io.Reader) ([]keyEntry, error) {
+	var result []keyEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		key, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		result = append(result, keyEntry{key: Key(key), name: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading key registry: %v", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,38 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// Package lw describes shared states of lightweight mutexes and sema mutexes.
+// The states are changed by the sync package and read by ipcctl, so both use the values below.
+package lw
+
+// lightweight mutex state is a 32-bit cell with one of the following values.
+const (
+	MutexStateSize         = 4
+	MutexUnlocked          = 0
+	MutexLockedNoWaiters   = 1
+	MutexLockedHaveWaiters = 2
+)
+
+// lightweight rwmutex state is a 64-bit cell with the following bits distribution:
+//
+//	....63...|62..61|..60..|59..............40|39..............20|19...............0|
+//	---------|------|------|------------------|------------------|------------------|
+//	upgrading|policy|writer| waiting writers  | waiting readers  |     readers      |
+const (
+	RWMutexStateSize          = 8
+	RWMutexMask               = 0xFFFFF
+	RWMutexWaitingReaderShift = 20
+	RWMutexWaitingWriterShift = 40
+	RWMutexWriterBit          = 1 << 60
+	RWMutexPolicyShift        = 61
+	RWMutexPolicyMask         = 0x3
+	RWMutexUpgradingBit       = 1 << 63
+)
+
+// sema mutex state is a lightweight mutex state followed by a 32-bit mode,
+// which tells, whether the mutex is the semaphore acquired with SEM_UNDO.
+const (
+	SemaMutexStateSize  = 8
+	SemaMutexModeOffset = 4
+	SemaMutexModeLw     = 1
+	SemaMutexModeUndo   = 2
+)
@@ -69,15 +69,14 @@ func runApp(command string, args []string, killChan <-chan bool) (*exec.Cmd, *by
 	buff := bytes.NewBuffer(nil)
 	cmd.Stderr = buff
 	cmd.Stdout = buff
+	prepareCommand(cmd)
 	if err := cmd.Start(); err != nil {
 		return nil, nil, err
 	}
 	if killChan != nil {
 		go func() {
 			if kill, ok := <-killChan; kill && ok {
-				if cmd.ProcessState != nil && !cmd.ProcessState.Exited() {
-					cmd.Process.Kill()
-				}
+				killCommand(cmd)
 			}
 		}()
 	}
@@ -0,0 +1,20 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build darwin freebsd linux
+
+package testutil
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// prepareCommand puts the command into a new process group.
+// 'go run' starts the program as its child, so the whole group must be killed.
+func prepareCommand(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killCommand(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,15 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package testutil
+
+import (
+	"os/exec"
+)
+
+func prepareCommand(cmd *exec.Cmd) {
+}
+
+// killCommand kills the command process only. If it was started by 'go run', the program itself keeps running.
+func killCommand(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
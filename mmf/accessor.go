@@ -0,0 +1,286 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package mmf
+
+import (
+	"encoding/binary"
+	"io"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// this is to ensure, that RegionAccessor satisfies io interfaces.
+var (
+	_ io.ReadWriteSeeker = (*RegionAccessor)(nil)
+	_ io.ReaderAt        = (*RegionAccessor)(nil)
+	_ io.WriterAt        = (*RegionAccessor)(nil)
+)
+
+// RegionAccessor provides bounds-checked access to a memory region.
+// It implements io.ReadWriteSeeker, io.ReaderAt, io.WriterAt and has methods
+// to read and write integers at given offsets using the accessor's byte order.
+// Atomic methods use the native byte order and require the offset to be properly aligned.
+// It holds a reference to the region, so the latter can't be gc'ed.
+// It always accesses region's current data, so it remains valid after the region is resized.
+// A RegionAccessor is not safe for concurrent use, except for ReadAt, WriteAt, and the methods
+// with explicit offsets, which do not change accessor's position.
+type RegionAccessor struct {
+	region *MemoryRegion
+	order  binary.ByteOrder
+	pos    int64
+}
+
+// NewRegionAccessor creates a new accessor for the given region.
+//	order - byte order for typed getters and setters. If it is nil, binary.LittleEndian is used.
+func NewRegionAccessor(region *MemoryRegion, order binary.ByteOrder) *RegionAccessor {
+	if order == nil {
+		order = binary.LittleEndian
+	}
+	return &RegionAccessor{region: region, order: order}
+}
+
+// Read is to implement io.Reader.
+func (a *RegionAccessor) Read(p []byte) (int, error) {
+	n, err := a.ReadAt(p, a.pos)
+	a.pos += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// Write is to implement io.Writer.
+func (a *RegionAccessor) Write(p []byte) (int, error) {
+	n, err := a.WriteAt(p, a.pos)
+	a.pos += int64(n)
+	return n, err
+}
+
+// Seek is to implement io.Seeker.
+// Seeking beyond the end of the region is allowed, but subsequent reads and writes will return io.EOF.
+func (a *RegionAccessor) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = a.pos + offset
+	case io.SeekEnd:
+		pos = int64(a.region.Size()) + offset
+	default:
+		return 0, errors.Errorf("invalid whence %d", whence)
+	}
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+	a.pos = pos
+	return pos, nil
+}
+
+// ReadAt is to implement io.ReaderAt.
+func (a *RegionAccessor) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	data := a.region.Data()
+	if off >= int64(len(data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt is to implement io.WriterAt.
+func (a *RegionAccessor) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	data := a.region.Data()
+	if off >= int64(len(data)) {
+		return 0, io.EOF
+	}
+	n := copy(data[off:], p)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Uint8At returns a byte at the given offset.
+func (a *RegionAccessor) Uint8At(off int64) (uint8, error) {
+	data, err := a.slice(off, 1)
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// Uint16At returns a uint16 at the given offset.
+func (a *RegionAccessor) Uint16At(off int64) (uint16, error) {
+	data, err := a.slice(off, 2)
+	if err != nil {
+		return 0, err
+	}
+	return a.order.Uint16(data), nil
+}
+
+// Uint32At returns a uint32 at the given offset.
+func (a *RegionAccessor) Uint32At(off int64) (uint32, error) {
+	data, err := a.slice(off, 4)
+	if err != nil {
+		return 0, err
+	}
+	return a.order.Uint32(data), nil
+}
+
+// Uint64At returns a uint64 at the given offset.
+func (a *RegionAccessor) Uint64At(off int64) (uint64, error) {
+	data, err := a.slice(off, 8)
+	if err != nil {
+		return 0, err
+	}
+	return a.order.Uint64(data), nil
+}
+
+// PutUint8At writes a byte at the given offset.
+func (a *RegionAccessor) PutUint8At(off int64, value uint8) error {
+	data, err := a.slice(off, 1)
+	if err != nil {
+		return err
+	}
+	data[0] = value
+	return nil
+}
+
+// PutUint16At writes a uint16 at the given offset.
+func (a *RegionAccessor) PutUint16At(off int64, value uint16) error {
+	data, err := a.slice(off, 2)
+	if err != nil {
+		return err
+	}
+	a.order.PutUint16(data, value)
+	return nil
+}
+
+// PutUint32At writes a uint32 at the given offset.
+func (a *RegionAccessor) PutUint32At(off int64, value uint32) error {
+	data, err := a.slice(off, 4)
+	if err != nil {
+		return err
+	}
+	a.order.PutUint32(data, value)
+	return nil
+}
+
+// PutUint64At writes a uint64 at the given offset.
+func (a *RegionAccessor) PutUint64At(off int64, value uint64) error {
+	data, err := a.slice(off, 8)
+	if err != nil {
+		return err
+	}
+	a.order.PutUint64(data, value)
+	return nil
+}
+
+// LoadUint32At atomically loads a uint32 at the given offset.
+func (a *RegionAccessor) LoadUint32At(off int64) (uint32, error) {
+	ptr, err := a.atomicPtr(off, 4)
+	if err != nil {
+		return 0, err
+	}
+	return atomic.LoadUint32((*uint32)(ptr)), nil
+}
+
+// StoreUint32At atomically stores a uint32 at the given offset.
+func (a *RegionAccessor) StoreUint32At(off int64, value uint32) error {
+	ptr, err := a.atomicPtr(off, 4)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint32((*uint32)(ptr), value)
+	return nil
+}
+
+// AddUint32At atomically adds delta to a uint32 at the given offset and returns the new value.
+func (a *RegionAccessor) AddUint32At(off int64, delta uint32) (uint32, error) {
+	ptr, err := a.atomicPtr(off, 4)
+	if err != nil {
+		return 0, err
+	}
+	return atomic.AddUint32((*uint32)(ptr), delta), nil
+}
+
+// CompareAndSwapUint32At executes the compare-and-swap operation for a uint32 at the given offset.
+func (a *RegionAccessor) CompareAndSwapUint32At(off int64, old, new uint32) (bool, error) {
+	ptr, err := a.atomicPtr(off, 4)
+	if err != nil {
+		return false, err
+	}
+	return atomic.CompareAndSwapUint32((*uint32)(ptr), old, new), nil
+}
+
+// LoadUint64At atomically loads a uint64 at the given offset.
+func (a *RegionAccessor) LoadUint64At(off int64) (uint64, error) {
+	ptr, err := a.atomicPtr(off, 8)
+	if err != nil {
+		return 0, err
+	}
+	return atomic.LoadUint64((*uint64)(ptr)), nil
+}
+
+// StoreUint64At atomically stores a uint64 at the given offset.
+func (a *RegionAccessor) StoreUint64At(off int64, value uint64) error {
+	ptr, err := a.atomicPtr(off, 8)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint64((*uint64)(ptr), value)
+	return nil
+}
+
+// AddUint64At atomically adds delta to a uint64 at the given offset and returns the new value.
+func (a *RegionAccessor) AddUint64At(off int64, delta uint64) (uint64, error) {
+	ptr, err := a.atomicPtr(off, 8)
+	if err != nil {
+		return 0, err
+	}
+	return atomic.AddUint64((*uint64)(ptr), delta), nil
+}
+
+// CompareAndSwapUint64At executes the compare-and-swap operation for a uint64 at the given offset.
+func (a *RegionAccessor) CompareAndSwapUint64At(off int64, old, new uint64) (bool, error) {
+	ptr, err := a.atomicPtr(off, 8)
+	if err != nil {
+		return false, err
+	}
+	return atomic.CompareAndSwapUint64((*uint64)(ptr), old, new), nil
+}
+
+// slice returns 'size' bytes of region's data starting at the offset.
+func (a *RegionAccessor) slice(off int64, size int) ([]byte, error) {
+	data := a.region.Data()
+	// off+size may overflow, so compare the size with the space left after the offset.
+	if off < 0 || off > int64(len(data)) || int64(size) > int64(len(data))-off {
+		return nil, errors.Errorf("offset %d is out of region bounds", off)
+	}
+	return data[off : off+int64(size)], nil
+}
+
+// atomicPtr returns a pointer to 'size' bytes of region's data starting at the offset,
+// checking, that it is aligned to 'size' bytes.
+func (a *RegionAccessor) atomicPtr(off int64, size int) (unsafe.Pointer, error) {
+	data, err := a.slice(off, size)
+	if err != nil {
+		return nil, err
+	}
+	ptr := unsafe.Pointer(&data[0])
+	if uintptr(ptr)%uintptr(size) != 0 {
+		return nil, errors.Errorf("offset %d is not aligned to %d bytes", off, size)
+	}
+	return ptr, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package mmf
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// O_COPY_ON_WRITE can be combined with OpenFile flags to create a private copy-on-write mapping.
+	// The changes are not written to the file, so the file is opened for reading only.
+	// Its value does not overlap with open flags on any of supported platforms.
+	O_COPY_ON_WRITE = 1 << 28
+)
+
+// FileRegion is a memory region, which maps a regular file and owns it.
+// Closing the region closes the file.
+type FileRegion struct {
+	*MemoryRegion
+	file *os.File
+}
+
+// OpenFile opens a file and maps it into memory.
+//	path - path to the file.
+//	flag - a combination of open flags from 'os' package and O_COPY_ON_WRITE.
+//		The mapping is writable, if the file is opened with os.O_RDWR, or O_COPY_ON_WRITE is set.
+//		os.O_WRONLY is not supported.
+//	perm - file permission bits, used, if the file is created.
+//	size - mapping size. If the file is smaller, and it is opened for writing, it is grown to the size.
+//		If it is 0, the whole file is mapped.
+// If the file was created by OpenFile, and the mapping failed, the file is removed.
+func OpenFile(path string, flag int, perm os.FileMode, size int64) (*FileRegion, error) {
+	mode := MEM_READ_ONLY
+	switch {
+	case flag&O_COPY_ON_WRITE != 0:
+		if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+			return nil, errors.New("copy-on-write mapping requires a read-only file")
+		}
+		flag &^= O_COPY_ON_WRITE
+		mode = MEM_COPY_ON_WRITE
+	case flag&os.O_RDWR != 0:
+		mode = MEM_READWRITE
+	case flag&os.O_WRONLY != 0:
+		return nil, errors.New("write-only mappings are not supported")
+	}
+	var created bool
+	if flag&os.O_CREATE != 0 {
+		_, err := os.Stat(path)
+		created = os.IsNotExist(err)
+	}
+	file, err := os.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open file")
+	}
+	result, err := newFileRegion(file, mode, size)
+	if err != nil {
+		file.Close()
+		if created {
+			os.Remove(path)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+func newFileRegion(file *os.File, mode int, size int64) (*FileRegion, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get file size")
+	}
+	if size == 0 {
+		size = stat.Size()
+	}
+	if size == 0 {
+		return nil, errors.New("cannot map an empty file")
+	}
+	if stat.Size() < size {
+		if mode != MEM_READWRITE {
+			return nil, errors.Errorf("the file is smaller (%d), than needed(%d)", stat.Size(), size)
+		}
+		if err = file.Truncate(size); err != nil {
+			return nil, errors.Wrap(err, "failed to grow the file")
+		}
+	}
+	region, err := NewMemoryRegion(file, mode, 0, int(size))
+	if err != nil {
+		return nil, err
+	}
+	return &FileRegion{MemoryRegion: region, file: file}, nil
+}
+
+// File returns the underlying file.
+func (r *FileRegion) File() *os.File {
+	return r.file
+}
+
+// Truncate changes the size of the file and resizes the mapping accordingly.
+// The region's data may move, so the slices previously returned by Data() must not be used after the call.
+// When the file grows, it is enlarged first, and if the mapping cannot be resized,
+// the file is truncated back to its previous size.
+// When the file shrinks, the mapping is shrunk first, so that it never extends past the end of the file,
+// and the data is not lost, if the mapping cannot be resized.
+func (r *FileRegion) Truncate(size int64) error {
+	if size <= 0 {
+		return errors.New("invalid size")
+	}
+	stat, err := r.file.Stat()
+	if err != nil {
+		return errors.Wrap(err, "failed to get file size")
+	}
+	if size < stat.Size() {
+		return r.shrink(size)
+	}
+	if err = r.file.Truncate(size); err != nil {
+		return errors.Wrap(err, "failed to truncate the file")
+	}
+	if err = r.Resize(int(size)); err != nil {
+		r.file.Truncate(stat.Size())
+		return errors.Wrap(err, "failed to resize the region")
+	}
+	return nil
+}
+
+func (r *FileRegion) shrink(size int64) error {
+	oldSize := r.Size()
+	if err := r.Resize(int(size)); err != nil {
+		return errors.Wrap(err, "failed to resize the region")
+	}
+	if err := r.file.Truncate(size); err != nil {
+		r.Resize(oldSize)
+		return errors.Wrap(err, "failed to truncate the file")
+	}
+	return nil
+}
+
+// Close unmaps the region and closes the file.
+func (r *FileRegion) Close() error {
+	errRegion, errFile := r.MemoryRegion.Close(), r.file.Close()
+	if errRegion != nil {
+		return errRegion
+	}
+	return errors.Wrap(errFile, "failed to close the file")
+}
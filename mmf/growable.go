@@ -0,0 +1,115 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package mmf
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// GrowableHeaderSize is the size of the header at the beginning of a GrowableRegion.
+	GrowableHeaderSize = 16
+)
+
+// growable region header layout: generation counter, then the current region size.
+type growableHeader struct {
+	gen  uint64
+	size uint64
+}
+
+// GrowableRegion implements a convention, which allows cooperating processes
+// to detect, that a shared region was grown, and to remap it on demand.
+// The first GrowableHeaderSize bytes of the region hold a generation counter and the current region size.
+// A process, which grows the region, enlarges the object, calls Grow, which resizes its mapping,
+// publishes the new size and increments the generation. Other processes call Refresh
+// before accessing the region, which remaps it, if the generation has changed.
+// Growers must be serialized by the caller, for instance, with a mutex,
+// as concurrent truncations of the object may shrink it.
+// A GrowableRegion is not safe for concurrent use.
+type GrowableRegion struct {
+	region *MemoryRegion
+	gen    uint64
+}
+
+// NewGrowableRegion returns a GrowableRegion on top of a writable region.
+// If the region's header is empty, it is initialized with the region's size.
+// If the region was already grown by another process, it is resized.
+func NewGrowableRegion(region *MemoryRegion) (*GrowableRegion, error) {
+	data := region.Data()
+	if len(data) < GrowableHeaderSize {
+		return nil, errors.New("the region is too small")
+	}
+	if uintptr(unsafe.Pointer(&data[0]))%8 != 0 {
+		return nil, errors.New("the region's data must be 8-byte aligned")
+	}
+	hdr := (*growableHeader)(unsafe.Pointer(&data[0]))
+	atomic.CompareAndSwapUint64(&hdr.size, 0, uint64(region.Size()))
+	result := &GrowableRegion{region: region, gen: ^atomic.LoadUint64(&hdr.gen)}
+	if _, err := result.Refresh(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Region returns the underlying memory region.
+// Its data must not be retained across calls to Grow and Refresh.
+func (r *GrowableRegion) Region() *MemoryRegion {
+	return r.region
+}
+
+// Grow resizes the region and notifies other processes about the new size.
+// The object must have been enlarged before the call.
+// If the region was grown by another process to a bigger size, Grow resizes it to that size.
+// The new size is published only after the region has been resized,
+// so other processes never see a size, which can't be mapped.
+func (r *GrowableRegion) Grow(size int) error {
+	if current := int(atomic.LoadUint64(&r.header().size)); size < current {
+		size = current
+	}
+	if size != r.region.Size() {
+		if err := r.region.Resize(size); err != nil {
+			return errors.Wrap(err, "failed to resize the region")
+		}
+	}
+	hdr := r.header()
+	for {
+		current := atomic.LoadUint64(&hdr.size)
+		if uint64(size) <= current {
+			break
+		}
+		if atomic.CompareAndSwapUint64(&hdr.size, current, uint64(size)) {
+			break
+		}
+	}
+	r.gen = atomic.AddUint64(&hdr.gen, 1)
+	return nil
+}
+
+// Refresh checks, whether the region was grown by another process, and remaps it, if so.
+// Returns true, if the region was remapped.
+func (r *GrowableRegion) Refresh() (bool, error) {
+	hdr := r.header()
+	// the size is published before the generation is incremented,
+	// so the size read after the generation is at least as big as the one of that generation.
+	gen := atomic.LoadUint64(&hdr.gen)
+	if gen == r.gen {
+		return false, nil
+	}
+	size := int(atomic.LoadUint64(&hdr.size))
+	if size == r.region.Size() {
+		r.gen = gen
+		return false, nil
+	}
+	if err := r.region.Resize(size); err != nil {
+		return false, errors.Wrap(err, "failed to resize the region")
+	}
+	r.gen = gen
+	return true, nil
+}
+
+func (r *GrowableRegion) header() *growableHeader {
+	return (*growableHeader)(unsafe.Pointer(&r.region.Data()[0]))
+}
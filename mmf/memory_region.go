@@ -19,6 +19,51 @@ const (
 	MEM_COPY_ON_WRITE = 0x00000008
 )
 
+// Mapping options. They can be combined with one of the modes above.
+const (
+	// MEM_HUGETLB maps the object using huge pages.
+	// The object must be a file on hugetlbfs or a memfd created with shm.MFD_HUGETLB,
+	// and the offset and the size must be multiples of the huge page size.
+	// Linux only, on other platforms NewMemoryRegion fails.
+	MEM_HUGETLB = 0x00000100
+	// MEM_POPULATE pre-faults the whole mapping, so that accessing it later does not block on page faults.
+	// Linux only, ignored on other platforms.
+	MEM_POPULATE = 0x00000200
+	// MEM_NORESERVE does not reserve swap space for the mapping.
+	// Linux only, ignored on other platforms.
+	MEM_NORESERVE = 0x00000400
+
+	memModeMask = 0x000000ff
+)
+
+// Protection flags for MemoryRegion.Protect.
+const (
+	PROT_NONE  = 0x00000000
+	PROT_READ  = 0x00000001
+	PROT_WRITE = 0x00000002
+)
+
+// Advice is a hint about how the region is going to be used.
+type Advice int
+
+// Advices for MemoryRegion.Advise. On windows all of them are ignored.
+const (
+	// AdviceNormal cancels previous advices.
+	AdviceNormal Advice = iota
+	// AdviceSequential tells, that the pages will be accessed sequentially, so they can be read ahead
+	// aggressively and freed soon after they were accessed.
+	AdviceSequential
+	// AdviceRandom tells, that the pages will be accessed in random order, so read ahead is useless.
+	AdviceRandom
+	// AdviceWillNeed tells, that the pages will be accessed soon, so they can be read in advance.
+	AdviceWillNeed
+	// AdviceDontNeed tells, that the pages will not be accessed soon, so their resources can be freed.
+	// For private mappings the changes are lost, and subsequent accesses see the object's contents.
+	AdviceDontNeed
+	// AdviceHugePage enables transparent huge pages for the region. Linux only.
+	AdviceHugePage
+)
+
 var (
 	mmapOffsetMultiple int64
 )
@@ -34,6 +79,7 @@ var (
 // 	}
 // region may be gc'ed while its data is used by g().
 // To avoid this, you can use UseMemoryRegion() or region readers/writers.
+// The region holds a reference to the object it maps, as the object may be needed to resize the region.
 type MemoryRegion struct {
 	*memoryRegion
 }
@@ -77,11 +123,70 @@ func (region *MemoryRegion) Flush(async bool) error {
 	return region.memoryRegion.Flush(async)
 }
 
+// FlushRange syncs the given range of mapped content with the file data.
+// offset is relative to the beginning of region's data.
+// The range is extended to the beginning of the page, which contains the first byte.
+func (region *MemoryRegion) FlushRange(offset, length int, async bool) error {
+	return region.memoryRegion.FlushRange(offset, length, async)
+}
+
+// Protect changes access protection of the given range of the region. prot is a combination of PROT_* flags.
+// offset is relative to the beginning of region's data.
+// Protection is changed for whole pages, so the range is extended to the beginning of the page,
+// which contains the first byte, and to the end of the page, which contains the last byte.
+// Write protection can't be given to a read-only mapping.
+// Accessing the memory in violation of its protection causes the program to crash.
+func (region *MemoryRegion) Protect(offset, length int, prot int) error {
+	return region.memoryRegion.Protect(offset, length, prot)
+}
+
 // Size returns mapping size.
 func (region *MemoryRegion) Size() int {
 	return region.memoryRegion.Size()
 }
 
+// Resize changes the size of the mapping. The new size must not exceed the size of the object.
+// On linux the mapping is resized with mremap, otherwise a new mapping is created,
+// and the old one is unmapped. The object must still be open, as the new size is checked against its size.
+// The region's data may move, so the slices previously returned by Data() must not be used after the call.
+// If the object is grown by another process, all the processes have to resize their regions.
+// GrowableRegion can be used to notify them.
+func (region *MemoryRegion) Resize(size int) error {
+	return region.memoryRegion.Resize(size)
+}
+
+// Advise gives the system a hint about how the region is going to be used.
+func (region *MemoryRegion) Advise(advice Advice) error {
+	return region.memoryRegion.Advise(advice)
+}
+
+// AdviseRange gives the system a hint about how the given range of the region is going to be used.
+// offset is relative to the beginning of region's data.
+// The range is extended to the beginning of the page, which contains the first byte.
+func (region *MemoryRegion) AdviseRange(offset, length int, advice Advice) error {
+	return region.memoryRegion.AdviseRange(offset, length, advice)
+}
+
+// Lock locks region's pages in memory, so that they are never paged out.
+// It may fail, if the process exceeds its locked memory limit (RLIMIT_MEMLOCK).
+func (region *MemoryRegion) Lock() error {
+	return region.memoryRegion.Lock()
+}
+
+// Unlock unlocks region's pages previously locked by Lock.
+func (region *MemoryRegion) Unlock() error {
+	return region.memoryRegion.Unlock()
+}
+
+// Resident reports, whether region's pages are resident in memory.
+// Element i of the result describes i-th page of the region,
+// the first page being the one, which contains the first byte of region's data.
+// The information may be outdated by the time the function returns.
+// Windows: not supported.
+func (region *MemoryRegion) Resident() ([]bool, error) {
+	return region.memoryRegion.Resident()
+}
+
 // UseMemoryRegion ensures, that the object is still alive at the moment of the call.
 // The usecase is when you use memory region's Data() and don't use the
 // region itself anymore. In this case the region can be gc'ed, the memory mapping
@@ -106,6 +211,20 @@ func calcMmapOffsetFixup(offset int64) int64 {
 	return (offset - (offset/mmapOffsetMultiple)*mmapOffsetMultiple)
 }
 
+// mappedRange returns a part of the mapped data, which contains bytes [offset, offset + length)
+// of region's data and starts at a valid mmap offset.
+//	data - all the mapped data.
+//	pageOffset - the offset of region's data in the mapped data.
+func mappedRange(data []byte, pageOffset int64, offset, length int) ([]byte, error) {
+	// offset+length may overflow, so compare the length with the space left after the offset.
+	available := len(data) - int(pageOffset)
+	if offset < 0 || length <= 0 || offset > available || length > available-offset {
+		return nil, errors.Errorf("invalid range of %d bytes at offset %d", length, offset)
+	}
+	start := pageOffset + int64(offset)
+	return data[start-calcMmapOffsetFixup(start) : start+int64(length)], nil
+}
+
 // fileInfoGetter is used to obtain file's size
 type fileInfoGetter interface {
 	Stat() (os.FileInfo, error)
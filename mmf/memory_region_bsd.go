@@ -0,0 +1,49 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build darwin freebsd
+
+package mmf
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+func (region *memoryRegion) Resize(size int) error {
+	if err := region.checkResize(size); err != nil {
+		return err
+	}
+	return region.remap(size)
+}
+
+func checkSeals(obj Mappable, prot, flags int) error {
+	return nil
+}
+
+func memFlagsFromOptions(opts int) (int, error) {
+	if opts&MEM_HUGETLB != 0 {
+		return 0, errors.New("huge pages are not supported")
+	}
+	if opts&^(MEM_POPULATE|MEM_NORESERVE) != 0 {
+		return 0, errors.Errorf("invalid memory region options %d", opts)
+	}
+	return 0, nil
+}
+
+func sysAdviceFromAdvice(advice Advice) (int, error) {
+	switch advice {
+	case AdviceNormal:
+		return unix.MADV_NORMAL, nil
+	case AdviceSequential:
+		return unix.MADV_SEQUENTIAL, nil
+	case AdviceRandom:
+		return unix.MADV_RANDOM, nil
+	case AdviceWillNeed:
+		return unix.MADV_WILLNEED, nil
+	case AdviceDontNeed:
+		return unix.MADV_DONTNEED, nil
+	case AdviceHugePage:
+		return 0, errors.New("huge pages are not supported")
+	}
+	return 0, errors.Errorf("invalid advice %d", advice)
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux
+
+package mmf
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/aybabtme/go-ipc/internal/allocator"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	cMREMAP_MAYMOVE = 1
+)
+
+// checkSeals returns an error, if the object is write-sealed, and a writable shared mapping is requested.
+// Without this check mmap would fail with a vague EPERM.
+func checkSeals(obj Mappable, prot, flags int) error {
+	if prot&unix.PROT_WRITE == 0 || flags&unix.MAP_SHARED == 0 || obj.Fd() == ^uintptr(0) {
+		return nil
+	}
+	// objects, which do not support sealing, return EINVAL.
+	seals, err := unix.FcntlInt(obj.Fd(), unix.F_GET_SEALS, 0)
+	if err != nil {
+		return nil
+	}
+	if seals&unix.F_SEAL_WRITE != 0 {
+		return errors.New("cannot create writable shared mapping of a write-sealed object")
+	}
+	return nil
+}
+
+func memFlagsFromOptions(opts int) (int, error) {
+	var flags int
+	if opts&MEM_HUGETLB != 0 {
+		flags |= unix.MAP_HUGETLB
+	}
+	if opts&MEM_POPULATE != 0 {
+		flags |= unix.MAP_POPULATE
+	}
+	if opts&MEM_NORESERVE != 0 {
+		flags |= unix.MAP_NORESERVE
+	}
+	if opts&^(MEM_HUGETLB|MEM_POPULATE|MEM_NORESERVE) != 0 {
+		return 0, errors.Errorf("invalid memory region options %d", opts)
+	}
+	return flags, nil
+}
+
+func sysAdviceFromAdvice(advice Advice) (int, error) {
+	switch advice {
+	case AdviceNormal:
+		return unix.MADV_NORMAL, nil
+	case AdviceSequential:
+		return unix.MADV_SEQUENTIAL, nil
+	case AdviceRandom:
+		return unix.MADV_RANDOM, nil
+	case AdviceWillNeed:
+		return unix.MADV_WILLNEED, nil
+	case AdviceDontNeed:
+		return unix.MADV_DONTNEED, nil
+	case AdviceHugePage:
+		return unix.MADV_HUGEPAGE, nil
+	}
+	return 0, errors.Errorf("invalid advice %d", advice)
+}
+
+func (region *memoryRegion) Resize(size int) error {
+	if err := region.checkResize(size); err != nil {
+		return err
+	}
+	data, err := mremap(region.data, size+int(region.pageOffset))
+	if err != nil {
+		// mremap may fail, for instance, for huge pages on old kernels. try to create a new mapping.
+		return region.remap(size)
+	}
+	region.data, region.size = data, size
+	return nil
+}
+
+func mremap(data []byte, size int) ([]byte, error) {
+	dataPointer := unsafe.Pointer(&data[0])
+	addr, _, err := unix.Syscall6(unix.SYS_MREMAP, uintptr(dataPointer), uintptr(len(data)), uintptr(size), cMREMAP_MAYMOVE, 0, 0)
+	if err != syscall.Errno(0) {
+		return nil, err
+	}
+	return allocator.ByteSliceFromUnsafePointer(*(*unsafe.Pointer)(unsafe.Pointer(&addr)), size, size), nil
+}
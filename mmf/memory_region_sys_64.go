@@ -0,0 +1,19 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build darwin freebsd,amd64 freebsd,arm64 linux,amd64 linux,arm64 linux,ppc64 linux,ppc64le linux,mips64 linux,mips64le
+
+package mmf
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func sysMmap(length uintptr, prot, flags, fd int, offset int64) (uintptr, error) {
+	addr, _, err := unix.Syscall6(unix.SYS_MMAP, 0, length, uintptr(prot), uintptr(flags), uintptr(fd), uintptr(offset))
+	if err != syscall.Errno(0) {
+		return 0, err
+	}
+	return addr, nil
+}
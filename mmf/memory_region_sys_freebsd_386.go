@@ -0,0 +1,18 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package mmf
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sysMmap passes the 64-bit offset as two 32-bit words.
+func sysMmap(length uintptr, prot, flags, fd int, offset int64) (uintptr, error) {
+	addr, _, err := unix.Syscall9(unix.SYS_MMAP, 0, length, uintptr(prot), uintptr(flags), uintptr(fd), uintptr(offset), uintptr(offset>>32), 0, 0)
+	if err != syscall.Errno(0) {
+		return 0, err
+	}
+	return addr, nil
+}
@@ -0,0 +1,20 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package mmf
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// sysMmap passes the arguments in memory, as mmap on s390x takes a pointer to them.
+func sysMmap(length uintptr, prot, flags, fd int, offset int64) (uintptr, error) {
+	args := [6]uintptr{0, length, uintptr(prot), uintptr(flags), uintptr(fd), uintptr(offset)}
+	addr, _, err := unix.Syscall(unix.SYS_MMAP, uintptr(unsafe.Pointer(&args[0])), 0, 0)
+	if err != syscall.Errno(0) {
+		return 0, err
+	}
+	return addr, nil
+}
@@ -0,0 +1,24 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux,386 linux,arm linux,mips linux,mipsle
+
+package mmf
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sysMmap uses mmap2, which takes the offset in 4096-byte units.
+func sysMmap(length uintptr, prot, flags, fd int, offset int64) (uintptr, error) {
+	page := uintptr(offset / 4096)
+	if offset != int64(page)*4096 {
+		return 0, syscall.EINVAL
+	}
+	addr, _, err := unix.Syscall6(unix.SYS_MMAP2, 0, length, uintptr(prot), uintptr(flags), uintptr(fd), page)
+	if err != syscall.Errno(0) {
+		return 0, err
+	}
+	return addr, nil
+}
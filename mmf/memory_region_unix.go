@@ -23,13 +23,23 @@ type memoryRegion struct {
 	data       []byte
 	size       int
 	pageOffset int64
+	// the following fields are used to remap the region on resize.
+	obj    Mappable
+	offset int64
+	prot   int
+	flags  int
 }
 
 func newMemoryRegion(obj Mappable, flag int, offset int64, size int) (*memoryRegion, error) {
-	prot, flags, err := memProtAndFlagsFromMode(flag)
+	prot, flags, err := memProtAndFlagsFromMode(flag & memModeMask)
 	if err != nil {
 		return nil, errors.Wrap(err, "memory region flags check failed")
 	}
+	optFlags, err := memFlagsFromOptions(flag &^ memModeMask)
+	if err != nil {
+		return nil, errors.Wrap(err, "memory region flags check failed")
+	}
+	flags |= optFlags
 	if size, err = checkMmapSize(obj, size); err != nil {
 		return nil, errors.Wrap(err, "size check failed")
 	}
@@ -42,18 +52,30 @@ func newMemoryRegion(obj Mappable, flag int, offset int64, size int) (*memoryReg
 	if calculatedSize > 0 && int64(size)+offset > calculatedSize {
 		return nil, errors.New("invalid mapping length")
 	}
+	if err = checkSeals(obj, prot, flags); err != nil {
+		return nil, err
+	}
 	pageOffset := calcMmapOffsetFixup(offset)
 	var data []byte
-	if data, err = unix.Mmap(int(obj.Fd()), offset-pageOffset, size+int(pageOffset), prot, flags); err != nil {
+	if data, err = mmap(int(obj.Fd()), offset-pageOffset, size+int(pageOffset), prot, flags); err != nil {
 		return nil, errors.Wrap(err, "mmap failed")
 	}
-	return &memoryRegion{data: data, size: size, pageOffset: pageOffset}, nil
+	return &memoryRegion{
+		data:       data,
+		size:       size,
+		pageOffset: pageOffset,
+		obj:        obj,
+		offset:     offset,
+		prot:       prot,
+		flags:      flags,
+	}, nil
 }
 
 func (region *memoryRegion) Close() error {
 	if region.data != nil {
-		err := unix.Munmap(region.data)
+		err := munmap(region.data)
 		region.data = nil
+		region.obj = nil
 		region.pageOffset = 0
 		region.size = 0
 		return errors.Wrap(err, "munmap failed")
@@ -61,6 +83,47 @@ func (region *memoryRegion) Close() error {
 	return nil
 }
 
+// checkResize checks, that the new size does not exceed the size of the object.
+// If the size of the object is unknown, the region can't be resized,
+// as the memory past the end of the object can't be accessed.
+func (region *memoryRegion) checkResize(size int) error {
+	if region.data == nil {
+		return errors.New("the region is closed")
+	}
+	if size <= 0 {
+		return errors.New("invalid region size")
+	}
+	if region.obj.Fd() == ^uintptr(0) {
+		return errors.New("cannot resize the region, as its object was closed")
+	}
+	calculatedSize, err := fileSizeFromFd(region.obj)
+	if err != nil {
+		return errors.Wrap(err, "file size check failed")
+	}
+	if calculatedSize <= 0 {
+		return errors.New("cannot resize the region, as the size of its object is unknown")
+	}
+	if int64(size)+region.offset > calculatedSize {
+		return errors.New("invalid mapping length")
+	}
+	return nil
+}
+
+// remap creates a new mapping of the given size and unmaps the old one.
+// If the new mapping can't be created, the region remains unchanged.
+func (region *memoryRegion) remap(size int) error {
+	data, err := mmap(int(region.obj.Fd()), region.offset-region.pageOffset, size+int(region.pageOffset), region.prot, region.flags)
+	if err != nil {
+		return errors.Wrap(err, "mmap failed")
+	}
+	if err = munmap(region.data); err != nil {
+		munmap(data)
+		return errors.Wrap(err, "munmap failed")
+	}
+	region.data, region.size = data, size
+	return nil
+}
+
 func (region *memoryRegion) Data() []byte {
 	return region.data[region.pageOffset:]
 }
@@ -76,10 +139,93 @@ func (region *memoryRegion) Flush(async bool) error {
 	return nil
 }
 
+func (region *memoryRegion) FlushRange(offset, length int, async bool) error {
+	data, err := mappedRange(region.data, region.pageOffset, offset, length)
+	if err != nil {
+		return err
+	}
+	flag := unix.MS_SYNC
+	if async {
+		flag = unix.MS_ASYNC
+	}
+	if err = msync(data, flag); err != nil {
+		return errors.Wrap(err, "mync failed")
+	}
+	return nil
+}
+
+func (region *memoryRegion) Protect(offset, length int, prot int) error {
+	data, err := mappedRange(region.data, region.pageOffset, offset, length)
+	if err != nil {
+		return err
+	}
+	sysProt := unix.PROT_NONE
+	if prot&PROT_READ != 0 {
+		sysProt |= unix.PROT_READ
+	}
+	if prot&PROT_WRITE != 0 {
+		sysProt |= unix.PROT_WRITE
+	}
+	if err = unix.Mprotect(data, sysProt); err != nil {
+		return errors.Wrap(os.NewSyscallError("mprotect", err), "failed to change memory region protection")
+	}
+	return nil
+}
+
 func (region *memoryRegion) Size() int {
 	return region.size
 }
 
+func (region *memoryRegion) Advise(advice Advice) error {
+	return region.advise(region.data, advice)
+}
+
+func (region *memoryRegion) AdviseRange(offset, length int, advice Advice) error {
+	data, err := mappedRange(region.data, region.pageOffset, offset, length)
+	if err != nil {
+		return err
+	}
+	return region.advise(data, advice)
+}
+
+func (region *memoryRegion) advise(data []byte, advice Advice) error {
+	sysAdvice, err := sysAdviceFromAdvice(advice)
+	if err != nil {
+		return err
+	}
+	if err = unix.Madvise(data, sysAdvice); err != nil {
+		return errors.Wrap(os.NewSyscallError("madvise", err), "failed to advise memory region")
+	}
+	return nil
+}
+
+func (region *memoryRegion) Lock() error {
+	if err := unix.Mlock(region.data); err != nil {
+		return errors.Wrap(os.NewSyscallError("mlock", err), "failed to lock memory region")
+	}
+	return nil
+}
+
+func (region *memoryRegion) Unlock() error {
+	if err := unix.Munlock(region.data); err != nil {
+		return errors.Wrap(os.NewSyscallError("munlock", err), "failed to unlock memory region")
+	}
+	return nil
+}
+
+func (region *memoryRegion) Resident() ([]bool, error) {
+	pageSize := os.Getpagesize()
+	vec := make([]byte, (len(region.data)+pageSize-1)/pageSize)
+	if err := mincore(region.data, vec); err != nil {
+		return nil, errors.Wrap(os.NewSyscallError("mincore", err), "failed to get memory region residency")
+	}
+	result := make([]bool, len(vec))
+	for i, b := range vec {
+		result[i] = b&1 != 0
+	}
+	return result, nil
+}
+
 func memProtAndFlagsFromMode(mode int) (prot, flags int, err error) {
 	switch mode {
 	case MEM_READ_ONLY:
@@ -101,6 +247,19 @@ func memProtAndFlagsFromMode(mode int) (prot, flags int, err error) {
 }
 
 // syscalls
+// mappings are created and removed with raw syscalls, and not with unix.Mmap and unix.Munmap,
+// as on linux they are resized with mremap, which the bookkeeping of unix.Mmap does not know about.
+func mmap(fd int, offset int64, length, prot, flags int) ([]byte, error) {
+	if length <= 0 {
+		return nil, syscall.EINVAL
+	}
+	addr, err := sysMmap(uintptr(length), prot, flags, fd, offset)
+	if err != nil {
+		return nil, err
+	}
+	return allocator.ByteSliceFromUnsafePointer(*(*unsafe.Pointer)(unsafe.Pointer(&addr)), length, length), nil
+}
+
 func msync(data []byte, flags int) error {
 	dataPointer := unsafe.Pointer(&data[0])
 	_, _, err := unix.Syscall(unix.SYS_MSYNC, uintptr(dataPointer), uintptr(len(data)), uintptr(flags))
@@ -110,3 +269,22 @@ func msync(data []byte, flags int) error {
 	}
 	return nil
 }
+
+func mincore(data []byte, vec []byte) error {
+	dataPointer, vecPointer := unsafe.Pointer(&data[0]), unsafe.Pointer(&vec[0])
+	_, _, err := unix.Syscall(unix.SYS_MINCORE, uintptr(dataPointer), uintptr(len(data)), uintptr(vecPointer))
+	allocator.Use(dataPointer)
+	allocator.Use(vecPointer)
+	if err != syscall.Errno(0) {
+		return err
+	}
+	return nil
+}
+
+func munmap(data []byte) error {
+	_, _, err := unix.Syscall(unix.SYS_MUNMAP, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), 0)
+	if err != syscall.Errno(0) {
+		return err
+	}
+	return nil
+}
@@ -27,6 +27,10 @@ type memoryRegion struct {
 	data       []byte
 	size       int
 	pageOffset int64
+	// the following fields are used to remap the region on resize.
+	obj    Mappable
+	mode   int
+	offset int64
 }
 
 type native interface {
@@ -34,10 +38,16 @@ type native interface {
 }
 
 func newMemoryRegion(obj Mappable, mode int, offset int64, size int) (*memoryRegion, error) {
-	prot, flags, err := sysProtAndFlagsFromFlag(mode)
+	prot, flags, err := sysProtAndFlagsFromFlag(mode & memModeMask)
 	if err != nil {
 		return nil, errors.Wrap(err, "memory region flags check failed")
 	}
+	if mode&MEM_HUGETLB != 0 {
+		return nil, errors.New("huge pages are not supported")
+	}
+	if mode&^(memModeMask|MEM_POPULATE|MEM_NORESERVE) != 0 {
+		return nil, errors.Errorf("invalid memory region options %d", mode&^memModeMask)
+	}
 	if size, err = checkMmapSize(obj, size); err != nil {
 		return nil, errors.Wrap(err, "size check failed")
 	}
@@ -77,6 +87,9 @@ func newMemoryRegion(obj Mappable, mode int, offset int64, size int) (*memoryReg
 		data:       allocator.ByteSliceFromUnsafePointer(unsafe.Pointer(addr), totalSize, totalSize),
 		size:       size,
 		pageOffset: pageOffset,
+		obj:        obj,
+		mode:       mode,
+		offset:     offset + pageOffset,
 	}, nil
 }
 
@@ -87,6 +100,26 @@ func (region *memoryRegion) Close() error {
 		return errors.Wrap(err, "UnmapViewOfFile failed")
 	}
 	region.data = nil
+	region.obj = nil
+	return nil
+}
+
+func (region *memoryRegion) Resize(size int) error {
+	if region.data == nil {
+		return errors.New("the region is closed")
+	}
+	if size <= 0 {
+		return errors.New("invalid region size")
+	}
+	newRegion, err := newMemoryRegion(region.obj, region.mode, region.offset, size)
+	if err != nil {
+		return err
+	}
+	if err = windows.UnmapViewOfFile(uintptr(allocator.ByteSliceData(region.data))); err != nil {
+		windows.UnmapViewOfFile(uintptr(allocator.ByteSliceData(newRegion.data)))
+		return errors.Wrap(err, "UnmapViewOfFile failed")
+	}
+	region.data, region.size = newRegion.data, newRegion.size
 	return nil
 }
 
@@ -106,6 +139,72 @@ func (region *memoryRegion) Flush(async bool) error {
 	return nil
 }
 
+func (region *memoryRegion) FlushRange(offset, length int, async bool) error {
+	data, err := mappedRange(region.data, region.pageOffset, offset, length)
+	if err != nil {
+		return err
+	}
+	if err = windows.FlushViewOfFile(uintptr(allocator.ByteSliceData(data)), uintptr(len(data))); err != nil {
+		return errors.Wrap(err, "FlushViewOfFile failed")
+	}
+	return nil
+}
+
+func (region *memoryRegion) Protect(offset, length int, prot int) error {
+	data, err := mappedRange(region.data, region.pageOffset, offset, length)
+	if err != nil {
+		return err
+	}
+	var sysProt uint32
+	switch {
+	case prot&PROT_WRITE != 0:
+		sysProt = windows.PAGE_READWRITE
+	case prot&PROT_READ != 0:
+		sysProt = windows.PAGE_READONLY
+	default:
+		sysProt = windows.PAGE_NOACCESS
+	}
+	var old uint32
+	if err = windows.VirtualProtect(uintptr(allocator.ByteSliceData(data)), uintptr(len(data)), sysProt, &old); err != nil {
+		return errors.Wrap(os.NewSyscallError("VirtualProtect", err), "failed to change memory region protection")
+	}
+	return nil
+}
+
+func (region *memoryRegion) AdviseRange(offset, length int, advice Advice) error {
+	if _, err := mappedRange(region.data, region.pageOffset, offset, length); err != nil {
+		return err
+	}
+	return region.Advise(advice)
+}
+
+func (region *memoryRegion) Advise(advice Advice) error {
+	if advice < AdviceNormal || advice > AdviceHugePage {
+		return errors.Errorf("invalid advice %d", advice)
+	}
+	return nil
+}
+
+func (region *memoryRegion) Lock() error {
+	err := windows.VirtualLock(uintptr(allocator.ByteSliceData(region.data)), uintptr(len(region.data)))
+	if err != nil {
+		return errors.Wrap(os.NewSyscallError("VirtualLock", err), "failed to lock memory region")
+	}
+	return nil
+}
+
+func (region *memoryRegion) Unlock() error {
+	err := windows.VirtualUnlock(uintptr(allocator.ByteSliceData(region.data)), uintptr(len(region.data)))
+	if err != nil {
+		return errors.Wrap(os.NewSyscallError("VirtualUnlock", err), "failed to unlock memory region")
+	}
+	return nil
+}
+
+func (region *memoryRegion) Resident() ([]bool, error) {
+	return nil, errors.New("residency query is not supported")
+}
+
 func sysProtAndFlagsFromFlag(mode int) (prot uint32, flags uint32, err error) {
 	switch mode {
 	case MEM_READ_ONLY:
@@ -3,9 +3,15 @@
 package mmf
 
 import (
+	"encoding/binary"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"syscall"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -122,6 +128,462 @@ func TestMmfFileCopy(t *testing.T) {
 	a.Equal(expected, actual)
 }
 
+func TestMmfOptions(t *testing.T) {
+	a := assert.New(t)
+	file, err := os.Open(testFile)
+	if !a.NoError(err) {
+		return
+	}
+	defer file.Close()
+	region, err := NewMemoryRegion(file, MEM_READ_ONLY|MEM_POPULATE|MEM_NORESERVE, 0, 0)
+	if !a.NoError(err) {
+		return
+	}
+	a.NoError(region.Close())
+	// a regular file can't be mapped with huge pages.
+	_, err = NewMemoryRegion(file, MEM_READ_ONLY|MEM_HUGETLB, 0, 0)
+	a.Error(err)
+	_, err = NewMemoryRegion(file, MEM_READ_ONLY|0x10000, 0, 0)
+	a.Error(err)
+	_, err = NewMemoryRegion(file, MEM_POPULATE, 0, 0)
+	a.Error(err)
+}
+
+func TestMmfAdvise(t *testing.T) {
+	a := assert.New(t)
+	file, err := os.Open(testFile)
+	if !a.NoError(err) {
+		return
+	}
+	defer file.Close()
+	region, err := NewMemoryRegion(file, MEM_READ_ONLY, 0, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(region.Close())
+	}()
+	for _, advice := range []Advice{AdviceSequential, AdviceRandom, AdviceWillNeed, AdviceDontNeed, AdviceNormal} {
+		a.NoError(region.Advise(advice))
+	}
+	a.Equal(byte(1), region.Data()[1])
+	a.Error(region.Advise(Advice(-1)))
+}
+
+func TestMmfLockResident(t *testing.T) {
+	a := assert.New(t)
+	file, err := os.Open(testFile)
+	if !a.NoError(err) {
+		return
+	}
+	defer file.Close()
+	region, err := NewMemoryRegion(file, MEM_READ_ONLY, 4097, 8192)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(region.Close())
+	}()
+	if !a.NoError(region.Lock()) {
+		return
+	}
+	if runtime.GOOS != "windows" {
+		resident, err := region.Resident()
+		a.NoError(err)
+		pageSize := os.Getpagesize()
+		a.Equal((4097%pageSize+8192+pageSize-1)/pageSize, len(resident))
+		for _, r := range resident {
+			a.True(r)
+		}
+	}
+	a.NoError(region.Unlock())
+}
+
+func createTempFile(size int64) (*os.File, error) {
+	file, err := ioutil.TempFile("", "mmf")
+	if err != nil {
+		return nil, err
+	}
+	if err = file.Truncate(size); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
+
+func TestMmfResize(t *testing.T) {
+	a := assert.New(t)
+	pageSize := os.Getpagesize()
+	file, err := createTempFile(int64(pageSize * 4))
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(file.Close())
+		a.NoError(os.Remove(file.Name()))
+	}()
+	region, err := NewMemoryRegion(file, MEM_READWRITE, 0, pageSize)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(region.Close())
+	}()
+	region.Data()[pageSize-1] = 1
+	if !a.NoError(region.Resize(pageSize * 3)) {
+		return
+	}
+	a.Equal(pageSize*3, region.Size())
+	a.Equal(pageSize*3, len(region.Data()))
+	a.Equal(byte(1), region.Data()[pageSize-1])
+	region.Data()[pageSize*3-1] = 3
+	a.Error(region.Resize(pageSize*4 + 1))
+	a.Equal(pageSize*3, region.Size())
+	if !a.NoError(region.Resize(pageSize * 2)) {
+		return
+	}
+	a.Equal(pageSize*2, len(region.Data()))
+	if !a.NoError(region.Resize(pageSize * 4)) {
+		return
+	}
+	a.Equal(byte(3), region.Data()[pageSize*3-1])
+}
+
+func TestMmfResizeClosedObject(t *testing.T) {
+	a := assert.New(t)
+	pageSize := os.Getpagesize()
+	file, err := createTempFile(int64(pageSize * 2))
+	if !a.NoError(err) {
+		return
+	}
+	defer os.Remove(file.Name())
+	region, err := NewMemoryRegion(file, MEM_READWRITE, 0, pageSize)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(region.Close())
+	}()
+	a.NoError(file.Close())
+	// the size of the object is unknown, so the region can't be resized.
+	a.Error(region.Resize(pageSize * 2))
+	a.Equal(pageSize, region.Size())
+}
+
+func TestMmfGrowableRegion(t *testing.T) {
+	a := assert.New(t)
+	pageSize := os.Getpagesize()
+	file, err := createTempFile(int64(pageSize))
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(file.Close())
+		a.NoError(os.Remove(file.Name()))
+	}()
+	region1, err := NewMemoryRegion(file, MEM_READWRITE, 0, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer region1.Close()
+	region2, err := NewMemoryRegion(file, MEM_READWRITE, 0, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer region2.Close()
+	g1, err := NewGrowableRegion(region1)
+	if !a.NoError(err) {
+		return
+	}
+	g2, err := NewGrowableRegion(region2)
+	if !a.NoError(err) {
+		return
+	}
+	updated, err := g2.Refresh()
+	a.NoError(err)
+	a.False(updated)
+	if !a.NoError(file.Truncate(int64(pageSize * 3))) {
+		return
+	}
+	if !a.NoError(g1.Grow(pageSize * 3)) {
+		return
+	}
+	// the object is not big enough, so the size must not be published.
+	a.Error(g1.Grow(pageSize * 4))
+	a.Equal(uint64(pageSize*3), atomic.LoadUint64(&g1.header().size))
+	g1.Region().Data()[pageSize*3-1] = 42
+	updated, err = g2.Refresh()
+	a.NoError(err)
+	a.True(updated)
+	a.Equal(pageSize*3, g2.Region().Size())
+	a.Equal(byte(42), g2.Region().Data()[pageSize*3-1])
+	updated, err = g2.Refresh()
+	a.NoError(err)
+	a.False(updated)
+	// a region opened after the growth is resized immediately.
+	region3, err := NewMemoryRegion(file, MEM_READWRITE, 0, pageSize)
+	if !a.NoError(err) {
+		return
+	}
+	defer region3.Close()
+	g3, err := NewGrowableRegion(region3)
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal(pageSize*3, g3.Region().Size())
+}
+
+func TestMmfRanges(t *testing.T) {
+	a := assert.New(t)
+	pageSize := os.Getpagesize()
+	file, err := createTempFile(int64(pageSize * 4))
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(file.Close())
+		a.NoError(os.Remove(file.Name()))
+	}()
+	region, err := NewMemoryRegion(file, MEM_READWRITE, 100, pageSize*3)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(region.Close())
+	}()
+	data := region.Data()
+	data[pageSize+1] = 42
+	a.NoError(region.FlushRange(pageSize+1, 1, false))
+	a.NoError(region.FlushRange(0, pageSize*3, true))
+	a.NoError(region.AdviseRange(pageSize, pageSize, AdviceWillNeed))
+	actual := make([]byte, 1)
+	_, err = file.ReadAt(actual, int64(pageSize+101))
+	a.NoError(err)
+	a.Equal(byte(42), actual[0])
+	a.Error(region.FlushRange(-1, 1, false))
+	a.Error(region.FlushRange(pageSize*3, 1, false))
+	a.Error(region.Protect(0, 0, PROT_READ))
+	a.Error(region.AdviseRange(1, pageSize*3, AdviceNormal))
+	// offset+length overflows.
+	maxInt := int(^uint(0) >> 1)
+	a.Error(region.FlushRange(pageSize, maxInt, false))
+	a.Error(region.AdviseRange(maxInt, maxInt, AdviceNormal))
+}
+
+func TestMmfProtect(t *testing.T) {
+	a := assert.New(t)
+	pageSize := os.Getpagesize()
+	file, err := createTempFile(int64(pageSize * 2))
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(file.Close())
+		a.NoError(os.Remove(file.Name()))
+	}()
+	region, err := NewMemoryRegion(file, MEM_READWRITE, 0, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(region.Close())
+	}()
+	data := region.Data()
+	if !a.NoError(region.Protect(pageSize, pageSize, PROT_READ)) {
+		return
+	}
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	a.NotPanics(func() { data[pageSize-1] = 1 })
+	a.Panics(func() { data[pageSize] = 1 })
+	a.NoError(region.Protect(pageSize, 1, PROT_READ|PROT_WRITE))
+	a.NotPanics(func() { data[pageSize] = 1 })
+	a.NoError(region.Protect(0, 1, PROT_NONE))
+	var sink byte
+	a.Panics(func() { sink = data[0] })
+	a.Equal(byte(0), sink)
+	a.NoError(region.Protect(0, 1, PROT_READ|PROT_WRITE))
+	a.Equal(byte(1), data[pageSize-1])
+}
+
+func TestRegionAccessor(t *testing.T) {
+	a := assert.New(t)
+	file, err := createTempFile(64)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(file.Close())
+		a.NoError(os.Remove(file.Name()))
+	}()
+	region, err := NewMemoryRegion(file, MEM_READWRITE, 0, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(region.Close())
+	}()
+	acc := NewRegionAccessor(region, binary.BigEndian)
+	n, err := acc.Write([]byte{1, 2, 3, 4})
+	a.NoError(err)
+	a.Equal(4, n)
+	v32, err := acc.Uint32At(0)
+	a.NoError(err)
+	a.Equal(uint32(0x01020304), v32)
+	a.NoError(acc.PutUint16At(4, 0x0506))
+	a.Equal([]byte{1, 2, 3, 4, 5, 6}, region.Data()[:6])
+	a.NoError(acc.PutUint64At(56, 0x0102030405060708))
+	v64, err := acc.Uint64At(56)
+	a.NoError(err)
+	a.Equal(uint64(0x0102030405060708), v64)
+	_, err = acc.Uint64At(57)
+	a.Error(err)
+	a.Error(acc.PutUint8At(64, 1))
+	_, err = acc.Uint16At(-1)
+	a.Error(err)
+	_, err = acc.Uint16At(math.MaxInt64 - 1)
+	a.Error(err)
+	a.Error(acc.PutUint64At(math.MaxInt64-1, 1))
+
+	pos, err := acc.Seek(-8, io.SeekEnd)
+	a.NoError(err)
+	a.Equal(int64(56), pos)
+	buf := make([]byte, 16)
+	n, err = acc.Read(buf)
+	a.NoError(err)
+	a.Equal(8, n)
+	n, err = acc.Read(buf)
+	a.Equal(io.EOF, err)
+	a.Equal(0, n)
+	n, err = acc.WriteAt(buf, 60)
+	a.Equal(io.EOF, err)
+	a.Equal(4, n)
+	n, err = acc.ReadAt(buf[:2], 1)
+	a.NoError(err)
+	a.Equal([]byte{2, 3}, buf[:2])
+	_, err = acc.Seek(-1, io.SeekStart)
+	a.Error(err)
+
+	le := NewRegionAccessor(region, nil)
+	v16, err := le.Uint16At(4)
+	a.NoError(err)
+	a.Equal(uint16(0x0605), v16)
+}
+
+func TestRegionAccessorAtomics(t *testing.T) {
+	a := assert.New(t)
+	file, err := createTempFile(64)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(file.Close())
+		a.NoError(os.Remove(file.Name()))
+	}()
+	region, err := NewMemoryRegion(file, MEM_READWRITE, 0, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(region.Close())
+	}()
+	acc := NewRegionAccessor(region, nil)
+	a.NoError(acc.StoreUint32At(4, 10))
+	v32, err := acc.AddUint32At(4, 5)
+	a.NoError(err)
+	a.Equal(uint32(15), v32)
+	swapped, err := acc.CompareAndSwapUint32At(4, 15, 20)
+	a.NoError(err)
+	a.True(swapped)
+	v32, err = acc.LoadUint32At(4)
+	a.NoError(err)
+	a.Equal(uint32(20), v32)
+	_, err = acc.LoadUint32At(2)
+	a.Error(err)
+
+	a.NoError(acc.StoreUint64At(8, 1<<40))
+	v64, err := acc.AddUint64At(8, 1)
+	a.NoError(err)
+	a.Equal(uint64(1<<40+1), v64)
+	swapped, err = acc.CompareAndSwapUint64At(8, 0, 1)
+	a.NoError(err)
+	a.False(swapped)
+	v64, err = acc.LoadUint64At(8)
+	a.NoError(err)
+	a.Equal(uint64(1<<40+1), v64)
+	_, err = acc.LoadUint64At(4)
+	a.Error(err)
+	_, err = acc.LoadUint64At(64)
+	a.Error(err)
+}
+
+func TestMmfCopyOnWriteFlag(t *testing.T) {
+	a := assert.New(t)
+	for _, flag := range []int{os.O_WRONLY, os.O_RDWR, os.O_APPEND, os.O_CREATE, os.O_EXCL, os.O_SYNC, os.O_TRUNC, syscall.O_NONBLOCK, syscall.O_CLOEXEC} {
+		a.Equal(0, flag&O_COPY_ON_WRITE)
+	}
+}
+
+func TestMmfOpenFile(t *testing.T) {
+	a := assert.New(t)
+	path := os.TempDir() + "/mmf-open.bin"
+	os.Remove(path)
+	defer os.Remove(path)
+	_, err := OpenFile(path, os.O_CREATE|os.O_RDWR, 0666, 0)
+	a.Error(err)
+	_, err = os.Stat(path)
+	a.True(os.IsNotExist(err))
+	region, err := OpenFile(path, os.O_CREATE|os.O_RDWR, 0666, 1024)
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal(1024, region.Size())
+	copy(region.Data(), "hello")
+	if !a.NoError(region.Truncate(4096)) {
+		return
+	}
+	a.Equal(4096, len(region.Data()))
+	copy(region.Data()[4091:], "world")
+	stat, err := region.File().Stat()
+	a.NoError(err)
+	a.Equal(int64(4096), stat.Size())
+	if !a.NoError(region.Truncate(2048)) {
+		return
+	}
+	a.Equal(2048, len(region.Data()))
+	a.Equal([]byte("hello"), region.Data()[:5])
+	stat, err = region.File().Stat()
+	a.NoError(err)
+	a.Equal(int64(2048), stat.Size())
+	a.NoError(region.Truncate(4096))
+	copy(region.Data()[4091:], "world")
+	a.NoError(region.Close())
+
+	_, err = OpenFile(path, os.O_RDONLY, 0, 8192)
+	a.Error(err)
+	_, err = OpenFile(path, os.O_WRONLY, 0, 0)
+	a.Error(err)
+	_, err = OpenFile(path, os.O_RDWR|O_COPY_ON_WRITE, 0, 0)
+	a.Error(err)
+
+	region, err = OpenFile(path, O_COPY_ON_WRITE, 0, 0)
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal(4096, region.Size())
+	a.Equal([]byte("hello"), region.Data()[:5])
+	copy(region.Data(), "HELLO")
+	a.NoError(region.Close())
+
+	region, err = OpenFile(path, os.O_RDONLY, 0, 0)
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal([]byte("hello"), region.Data()[:5])
+	a.Equal([]byte("world"), region.Data()[4091:])
+	a.NoError(region.Close())
+}
+
 func ExampleMemoryRegion() {
 	// this example shows how to copy a file using mmf.
 
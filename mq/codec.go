@@ -0,0 +1,203 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package mq
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/aybabtme/go-ipc/internal/allocator"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultMsgBufferSize is the size of the receive buffer used by TypedReceiver and Stream
+	// if the messenger does not report its max message size.
+	defaultMsgBufferSize = 8192
+)
+
+var (
+	// BinaryCodec copies the memory of an object as is.
+	// The object must be stored continuously in the memory, ie must not contain any references.
+	// It is the fastest codec, but both sides must share the same architecture and type layout.
+	BinaryCodec Codec = binaryCodec{}
+	// GobCodec encodes objects with encoding/gob. Each message carries its own type information.
+	GobCodec Codec = gobCodec{}
+	// JSONCodec encodes objects with encoding/json.
+	JSONCodec Codec = jsonCodec{}
+)
+
+// Codec converts objects into messages and back.
+type Codec interface {
+	// Encode returns a byte representation of the object.
+	Encode(object interface{}) ([]byte, error)
+	// Decode fills the object, which must be a pointer, with the data.
+	Decode(data []byte, object interface{}) error
+}
+
+// SizeLimited is an object with a limit on the message size.
+type SizeLimited interface {
+	MaxMsgSize() int
+}
+
+// TypedSender encodes objects of type T with a codec and sends them into a Messenger.
+type TypedSender[T any] struct {
+	mq    Messenger
+	codec Codec
+}
+
+// NewTypedSender returns new TypedSender for the given messenger and codec.
+func NewTypedSender[T any](mq Messenger, codec Codec) *TypedSender[T] {
+	return &TypedSender[T]{mq: mq, codec: codec}
+}
+
+// Send encodes and sends the object.
+// If the messenger is SizeLimited, and the encoded object is too big,
+// it returns an error without sending anything.
+func (s *TypedSender[T]) Send(object T) error {
+	data, err := s.encode(&object)
+	if err != nil {
+		return err
+	}
+	return s.mq.Send(data)
+}
+
+// SendTimeout encodes and sends the object, waiting for not longer, than the timeout.
+// The messenger must be a TimedMessenger.
+func (s *TypedSender[T]) SendTimeout(object T, timeout time.Duration) error {
+	tmq, ok := s.mq.(TimedMessenger)
+	if !ok {
+		return errors.New("the messenger does not support timeouts")
+	}
+	data, err := s.encode(&object)
+	if err != nil {
+		return err
+	}
+	return tmq.SendTimeout(data, timeout)
+}
+
+func (s *TypedSender[T]) encode(object *T) ([]byte, error) {
+	data, err := s.codec.Encode(object)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode the object")
+	}
+	if maxSize, ok := maxMsgSize(s.mq); ok && len(data) > maxSize {
+		return nil, errors.Errorf("the encoded message of %d bytes exceeds max message size of %d bytes", len(data), maxSize)
+	}
+	return data, nil
+}
+
+// TypedReceiver receives messages from a Messenger and decodes them into objects of type T.
+type TypedReceiver[T any] struct {
+	mq    Messenger
+	codec Codec
+	buff  []byte
+}
+
+// NewTypedReceiver returns new TypedReceiver for the given messenger and codec.
+// If the messenger is SizeLimited, its receive buffer is of max message size,
+// otherwise, it is 8192 bytes.
+func NewTypedReceiver[T any](mq Messenger, codec Codec) *TypedReceiver[T] {
+	size, ok := maxMsgSize(mq)
+	if !ok {
+		size = defaultMsgBufferSize
+	}
+	return &TypedReceiver[T]{mq: mq, codec: codec, buff: make([]byte, size)}
+}
+
+// Receive receives a message and decodes it.
+func (r *TypedReceiver[T]) Receive() (T, error) {
+	var result T
+	l, err := r.mq.Receive(r.buff)
+	if err != nil {
+		return result, err
+	}
+	return result, r.decode(r.buff[:l], &result)
+}
+
+// ReceiveTimeout receives a message and decodes it, waiting for not longer, than the timeout.
+// The messenger must be a TimedMessenger.
+func (r *TypedReceiver[T]) ReceiveTimeout(timeout time.Duration) (T, error) {
+	var result T
+	tmq, ok := r.mq.(TimedMessenger)
+	if !ok {
+		return result, errors.New("the messenger does not support timeouts")
+	}
+	l, err := tmq.ReceiveTimeout(r.buff, timeout)
+	if err != nil {
+		return result, err
+	}
+	return result, r.decode(r.buff[:l], &result)
+}
+
+func (r *TypedReceiver[T]) decode(data []byte, object *T) error {
+	if err := r.codec.Decode(data, object); err != nil {
+		return errors.Wrap(err, "failed to decode the message")
+	}
+	return nil
+}
+
+type binaryCodec struct{}
+
+func (binaryCodec) Encode(object interface{}) ([]byte, error) {
+	data, err := allocator.ObjectData(object)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]byte, len(data))
+	copy(result, data)
+	allocator.UseValue(object)
+	return result, nil
+}
+
+func (binaryCodec) Decode(data []byte, object interface{}) error {
+	value := reflect.ValueOf(object)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return errors.New("the object must be a non-nil pointer")
+	}
+	target, err := allocator.ObjectData(object)
+	if err != nil {
+		return err
+	}
+	if len(target) != len(data) {
+		return errors.Errorf("invalid message size %d, expected %d bytes", len(data), len(target))
+	}
+	copy(target, data)
+	allocator.UseValue(object)
+	return nil
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(object interface{}) ([]byte, error) {
+	var buff bytes.Buffer
+	if err := gob.NewEncoder(&buff).Encode(object); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+func (gobCodec) Decode(data []byte, object interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(object)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(object interface{}) ([]byte, error) {
+	return json.Marshal(object)
+}
+
+func (jsonCodec) Decode(data []byte, object interface{}) error {
+	return json.Unmarshal(data, object)
+}
+
+func maxMsgSize(mq Messenger) (int, bool) {
+	if sl, ok := mq.(SizeLimited); ok {
+		return sl.MaxMsgSize(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,97 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package mq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type codecTestStruct struct {
+	ID    int
+	Value float64
+	Data  [16]byte
+}
+
+type codecTestRefStruct struct {
+	Name  string
+	Items []int
+}
+
+func testTypedMq(t *testing.T, codec Codec) {
+	a := assert.New(t)
+	a.NoError(DestroyFastMq(testMqName))
+	mq, err := CreateFastMq(testMqName, 0, 0666, 4, 1024)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(mq.Destroy())
+	}()
+	sender, receiver := NewTypedSender[codecTestStruct](mq, codec), NewTypedReceiver[codecTestStruct](mq, codec)
+	expected := codecTestStruct{ID: 42, Value: 3.14, Data: [16]byte{1, 2, 3}}
+	if !a.NoError(sender.Send(expected)) {
+		return
+	}
+	received, err := receiver.Receive()
+	a.NoError(err)
+	a.Equal(expected, received)
+}
+
+func TestTypedMqBinaryCodec(t *testing.T) {
+	testTypedMq(t, BinaryCodec)
+}
+
+func TestTypedMqGobCodec(t *testing.T) {
+	testTypedMq(t, GobCodec)
+}
+
+func TestTypedMqJSONCodec(t *testing.T) {
+	testTypedMq(t, JSONCodec)
+}
+
+func TestTypedMqReferenceTypes(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DestroyFastMq(testMqName))
+	mq, err := CreateFastMq(testMqName, 0, 0666, 4, 1024)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(mq.Destroy())
+	}()
+	expected := codecTestRefStruct{Name: "test", Items: []int{1, 2, 3}}
+	a.Error(NewTypedSender[codecTestRefStruct](mq, BinaryCodec).Send(expected))
+	sender, receiver := NewTypedSender[codecTestRefStruct](mq, GobCodec), NewTypedReceiver[codecTestRefStruct](mq, GobCodec)
+	if !a.NoError(sender.Send(expected)) {
+		return
+	}
+	received, err := receiver.Receive()
+	a.NoError(err)
+	a.Equal(expected, received)
+}
+
+func TestTypedMqTooBig(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DestroyFastMq(testMqName))
+	mq, err := CreateFastMq(testMqName, O_NONBLOCK, 0666, 1, 8)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(mq.Destroy())
+	}()
+	a.Error(NewTypedSender[codecTestStruct](mq, BinaryCodec).Send(codecTestStruct{}))
+	a.True(mq.Empty())
+	a.NoError(NewTypedSender[uint64](mq, BinaryCodec).Send(0xDEADBEEF))
+	received, err := NewTypedReceiver[uint64](mq, BinaryCodec).Receive()
+	a.NoError(err)
+	a.Equal(uint64(0xDEADBEEF), received)
+}
+
+func TestBinaryCodecDecodeSizeMismatch(t *testing.T) {
+	var value uint32
+	assert.Error(t, BinaryCodec.Decode(make([]byte, 8), &value))
+	assert.Error(t, BinaryCodec.Decode(make([]byte, 4), value))
+}
@@ -0,0 +1,319 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package mq
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultReassemblyTimeout is the default time a partially received message
+	// is kept by FragmentedMessenger before it is reported as incomplete.
+	DefaultReassemblyTimeout = 5 * time.Second
+
+	// DefaultMaxFragmentedMessageSize is the default max size of a message,
+	// which can be sent or received by FragmentedMessenger.
+	DefaultMaxFragmentedMessageSize = 16 * 1024 * 1024
+
+	// fragment header layout: sender id, message id, fragment index, fragments count.
+	fragmentHdrSize = 16
+)
+
+var (
+	// ErrIncompleteMessage is returned by FragmentedMessenger, when some fragments of a message
+	// have not been received within the reassembly timeout.
+	ErrIncompleteMessage = errors.New("incomplete fragmented message")
+)
+
+// this is to ensure, that FragmentedMessenger satisfies queue interfaces.
+var (
+	_ Messenger      = (*FragmentedMessenger)(nil)
+	_ TimedMessenger = (*FragmentedMessenger)(nil)
+)
+
+type fragmentHdr struct {
+	sender uint32
+	id     uint32
+	index  uint32
+	count  uint32
+}
+
+type fragmentKey struct {
+	sender uint32
+	id     uint32
+}
+
+type partialMessage struct {
+	parts    [][]byte
+	received int
+	size     int
+	started  time.Time
+}
+
+// FragmentedMessenger is a Messenger wrapper, which splits messages larger than
+// max message size of the underlying messenger into numbered fragments and reassembles them on receive.
+// Fragments sent by different FragmentedMessenger instances are told apart by a random sender id,
+// so several senders may share one queue. Fragments of a message may arrive in any order.
+// If a message has not been fully received within the reassembly timeout, it is dropped
+// on the next Receive call, which returns an error, which cause is ErrIncompleteMessage.
+// Messages larger than max message size (see SetMaxMessageSize) are rejected.
+// All the parties must use FragmentedMessenger with the same max message size.
+// A FragmentedMessenger is not safe for concurrent use.
+type FragmentedMessenger struct {
+	mq        Messenger
+	msgSize   int
+	sender    uint32
+	nextID    uint32
+	maxSize   int
+	timeout   time.Duration
+	sendBuff  []byte
+	recvBuff  []byte
+	pending   map[fragmentKey]*partialMessage
+	assembled []byte
+}
+
+// NewFragmentedMessenger returns new FragmentedMessenger on top of the given messenger.
+//	mq - underlying messenger. It is closed, when the FragmentedMessenger is closed.
+//	msgSize - max message size of the underlying messenger.
+//		If it is 0, mq must be SizeLimited.
+func NewFragmentedMessenger(mq Messenger, msgSize int) (*FragmentedMessenger, error) {
+	if msgSize == 0 {
+		var ok bool
+		if msgSize, ok = maxMsgSize(mq); !ok {
+			return nil, errors.New("cannot determine max message size of the messenger")
+		}
+	}
+	if msgSize <= fragmentHdrSize {
+		return nil, errors.Errorf("max message size must be greater, than %d bytes", fragmentHdrSize)
+	}
+	var rnd [4]byte
+	if _, err := rand.Read(rnd[:]); err != nil {
+		return nil, errors.Wrap(err, "failed to generate sender id")
+	}
+	return &FragmentedMessenger{
+		mq:       mq,
+		msgSize:  msgSize,
+		sender:   binary.LittleEndian.Uint32(rnd[:]),
+		maxSize:  DefaultMaxFragmentedMessageSize,
+		timeout:  DefaultReassemblyTimeout,
+		sendBuff: make([]byte, msgSize),
+		recvBuff: make([]byte, msgSize),
+		pending:  make(map[fragmentKey]*partialMessage),
+	}, nil
+}
+
+// SetReassemblyTimeout sets how long a partially received message is kept.
+func (m *FragmentedMessenger) SetReassemblyTimeout(timeout time.Duration) {
+	m.timeout = timeout
+}
+
+// SetMaxMessageSize sets max size of a whole message. Fragment headers claiming
+// a bigger message are rejected before any memory is allocated for it.
+// All the parties must use the same value.
+func (m *FragmentedMessenger) SetMaxMessageSize(size int) error {
+	if size <= 0 {
+		return errors.Errorf("invalid max message size %d", size)
+	}
+	m.maxSize = size
+	return nil
+}
+
+// Send splits the data into fragments and sends them.
+// It blocks if the queue is full.
+func (m *FragmentedMessenger) Send(data []byte) error {
+	return m.send(data, m.mq.Send)
+}
+
+// SendTimeout splits the data into fragments and sends them.
+// It blocks if the queue is full, waiting for not longer, than the timeout for all the fragments.
+// The underlying messenger must be a TimedMessenger.
+func (m *FragmentedMessenger) SendTimeout(data []byte, timeout time.Duration) error {
+	tmq, ok := m.mq.(TimedMessenger)
+	if !ok {
+		return errors.New("the messenger does not support timeouts")
+	}
+	deadline := time.Now().Add(timeout)
+	return m.send(data, func(fragment []byte) error {
+		return tmq.SendTimeout(fragment, remainingTimeout(timeout, deadline))
+	})
+}
+
+// Receive receives fragments until a whole message is assembled and copies it into data.
+// It blocks if the queue is empty. Returns message len.
+func (m *FragmentedMessenger) Receive(data []byte) (int, error) {
+	return m.receive(data, m.mq.Receive)
+}
+
+// ReceiveTimeout receives fragments until a whole message is assembled and copies it into data.
+// It blocks if the queue is empty, waiting for not longer, than the timeout for all the fragments.
+// The underlying messenger must be a TimedMessenger.
+func (m *FragmentedMessenger) ReceiveTimeout(data []byte, timeout time.Duration) (int, error) {
+	tmq, ok := m.mq.(TimedMessenger)
+	if !ok {
+		return 0, errors.New("the messenger does not support timeouts")
+	}
+	deadline := time.Now().Add(timeout)
+	return m.receive(data, func(fragment []byte) (int, error) {
+		return tmq.ReceiveTimeout(fragment, remainingTimeout(timeout, deadline))
+	})
+}
+
+// Close closes the underlying messenger.
+func (m *FragmentedMessenger) Close() error {
+	m.pending = nil
+	m.assembled = nil
+	return m.mq.Close()
+}
+
+func (m *FragmentedMessenger) send(data []byte, sender func([]byte) error) error {
+	if len(data) > m.maxSize {
+		return errors.Errorf("the message of %d bytes exceeds max message size of %d bytes", len(data), m.maxSize)
+	}
+	payloadSize := m.payloadSize()
+	count := (len(data) + payloadSize - 1) / payloadSize
+	if count == 0 {
+		count = 1
+	}
+	hdr := fragmentHdr{sender: m.sender, id: m.nextID, count: uint32(count)}
+	m.nextID++
+	for i := 0; i < count; i++ {
+		hdr.index = uint32(i)
+		chunk := data[i*payloadSize:]
+		if len(chunk) > payloadSize {
+			chunk = chunk[:payloadSize]
+		}
+		putFragmentHdr(m.sendBuff, hdr)
+		l := fragmentHdrSize + copy(m.sendBuff[fragmentHdrSize:], chunk)
+		if err := sender(m.sendBuff[:l]); err != nil {
+			return errors.Wrapf(err, "failed to send fragment %d of %d", i+1, count)
+		}
+	}
+	return nil
+}
+
+func (m *FragmentedMessenger) receive(data []byte, receiver func([]byte) (int, error)) (int, error) {
+	for m.assembled == nil {
+		if err := m.dropExpired(); err != nil {
+			return 0, err
+		}
+		l, err := receiver(m.recvBuff)
+		if err != nil {
+			return 0, err
+		}
+		if l < fragmentHdrSize {
+			return 0, errors.Errorf("invalid fragment of %d bytes", l)
+		}
+		hdr := getFragmentHdr(m.recvBuff)
+		if err = m.addFragment(hdr, m.recvBuff[fragmentHdrSize:l]); err != nil {
+			return 0, err
+		}
+	}
+	if len(data) < len(m.assembled) {
+		return 0, errors.Errorf("the buffer of %d bytes is too small for a %d bytes message", len(data), len(m.assembled))
+	}
+	l := copy(data, m.assembled)
+	m.assembled = nil
+	return l, nil
+}
+
+func (m *FragmentedMessenger) addFragment(hdr fragmentHdr, payload []byte) error {
+	if hdr.count == 0 || hdr.index >= hdr.count || hdr.count > m.maxFragments() {
+		return errors.Errorf("invalid fragment %d of %d", hdr.index, hdr.count)
+	}
+	// all the fragments except the last one carry exactly payloadSize bytes.
+	if hdr.index < hdr.count-1 && len(payload) != m.payloadSize() {
+		return errors.Errorf("invalid size %d of fragment %d of %d", len(payload), hdr.index, hdr.count)
+	}
+	if hdr.count == 1 {
+		m.assembled = make([]byte, len(payload))
+		copy(m.assembled, payload)
+		return nil
+	}
+	key := fragmentKey{sender: hdr.sender, id: hdr.id}
+	msg, found := m.pending[key]
+	if !found {
+		msg = &partialMessage{parts: make([][]byte, hdr.count), started: time.Now()}
+		m.pending[key] = msg
+	}
+	if int(hdr.count) != len(msg.parts) {
+		delete(m.pending, key)
+		return errors.Errorf("fragments count mismatch for message %d from sender %x", hdr.id, hdr.sender)
+	}
+	if msg.parts[hdr.index] != nil {
+		return errors.Errorf("duplicate fragment %d of message %d from sender %x", hdr.index, hdr.id, hdr.sender)
+	}
+	msg.parts[hdr.index] = make([]byte, len(payload))
+	copy(msg.parts[hdr.index], payload)
+	msg.received++
+	msg.size += len(payload)
+	if msg.received == len(msg.parts) {
+		delete(m.pending, key)
+		m.assembled = make([]byte, 0, msg.size)
+		for _, part := range msg.parts {
+			m.assembled = append(m.assembled, part...)
+		}
+	}
+	return nil
+}
+
+func (m *FragmentedMessenger) payloadSize() int {
+	return m.msgSize - fragmentHdrSize
+}
+
+// maxFragments returns the number of fragments of a message of max size.
+func (m *FragmentedMessenger) maxFragments() uint32 {
+	payloadSize := m.payloadSize()
+	count := (m.maxSize + payloadSize - 1) / payloadSize
+	if count == 0 {
+		count = 1
+	}
+	return uint32(count)
+}
+
+// dropExpired removes messages, which have not been reassembled within the timeout,
+// and returns ErrIncompleteMessage, if there were any.
+func (m *FragmentedMessenger) dropExpired() error {
+	var dropped int
+	for key, msg := range m.pending {
+		if time.Since(msg.started) > m.timeout {
+			delete(m.pending, key)
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		return errors.Wrapf(ErrIncompleteMessage, "%d message(s) dropped", dropped)
+	}
+	return nil
+}
+
+func putFragmentHdr(data []byte, hdr fragmentHdr) {
+	binary.LittleEndian.PutUint32(data[0:], hdr.sender)
+	binary.LittleEndian.PutUint32(data[4:], hdr.id)
+	binary.LittleEndian.PutUint32(data[8:], hdr.index)
+	binary.LittleEndian.PutUint32(data[12:], hdr.count)
+}
+
+func getFragmentHdr(data []byte) fragmentHdr {
+	return fragmentHdr{
+		sender: binary.LittleEndian.Uint32(data[0:]),
+		id:     binary.LittleEndian.Uint32(data[4:]),
+		index:  binary.LittleEndian.Uint32(data[8:]),
+		count:  binary.LittleEndian.Uint32(data[12:]),
+	}
+}
+
+// remainingTimeout returns the time left until the deadline.
+// Negative timeouts are infinite and returned as is.
+func remainingTimeout(timeout time.Duration, deadline time.Time) time.Duration {
+	if timeout < 0 {
+		return timeout
+	}
+	if left := time.Until(deadline); left > 0 {
+		return left
+	}
+	return 0
+}
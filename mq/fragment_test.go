@@ -0,0 +1,193 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package mq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func fragmentTestData(size int, seed byte) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i) + seed
+	}
+	return data
+}
+
+func TestFragmentedMessengerBigMessage(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DestroyFastMq(testMqName))
+	mq, err := CreateFastMq(testMqName, 0, 0666, 16, 64)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(DestroyFastMq(testMqName))
+	}()
+	fm, err := NewFragmentedMessenger(mq, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(fm.Close())
+	}()
+	for _, size := range []int{0, 1, 48, 49, 500} {
+		data := fragmentTestData(size, byte(size))
+		if !a.NoError(fm.Send(data)) {
+			return
+		}
+		received := make([]byte, 512)
+		l, err := fm.Receive(received)
+		if a.NoError(err) {
+			a.Equal(data, received[:l])
+		}
+	}
+	a.NoError(fm.Send(fragmentTestData(200, 0)))
+	_, err = fm.Receive(make([]byte, 100))
+	a.Error(err)
+	l, err := fm.Receive(make([]byte, 200))
+	a.NoError(err)
+	a.Equal(200, l)
+}
+
+func TestFragmentedMessengerInterleaved(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DestroyFastMq(testMqName))
+	mq, err := CreateFastMq(testMqName, 0, 0666, 32, 32)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(mq.Destroy())
+	}()
+	fm1, err := NewFragmentedMessenger(mq, 0)
+	if !a.NoError(err) {
+		return
+	}
+	fm2, err := NewFragmentedMessenger(mq, 0)
+	if !a.NoError(err) {
+		return
+	}
+	data1, data2 := fragmentTestData(100, 1), fragmentTestData(100, 2)
+	// interleave fragments of two senders manually.
+	var frags1, frags2 [][]byte
+	a.NoError(fm1.send(data1, func(f []byte) error { frags1 = append(frags1, append([]byte(nil), f...)); return nil }))
+	a.NoError(fm2.send(data2, func(f []byte) error { frags2 = append(frags2, append([]byte(nil), f...)); return nil }))
+	a.Equal(len(frags1), len(frags2))
+	for i := range frags1 {
+		a.NoError(mq.Send(frags2[len(frags2)-i-1]))
+		a.NoError(mq.Send(frags1[i]))
+	}
+	results := make(map[byte][]byte)
+	for i := 0; i < 2; i++ {
+		received := make([]byte, 100)
+		l, err := fm1.Receive(received)
+		if a.NoError(err) && a.Equal(100, l) {
+			results[received[0]] = received
+		}
+	}
+	a.Equal(data1, results[1])
+	a.Equal(data2, results[2])
+}
+
+func TestFragmentedMessengerIncomplete(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DestroyFastMq(testMqName))
+	mq, err := CreateFastMq(testMqName, 0, 0666, 16, 32)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(mq.Destroy())
+	}()
+	fm, err := NewFragmentedMessenger(mq, 0)
+	if !a.NoError(err) {
+		return
+	}
+	fm.SetReassemblyTimeout(time.Millisecond * 50)
+	var first []byte
+	a.NoError(fm.send(fragmentTestData(40, 0), func(f []byte) error {
+		if first == nil {
+			first = append([]byte(nil), f...)
+		}
+		return nil
+	}))
+	a.NoError(mq.Send(first))
+	received := make([]byte, 64)
+	_, err = fm.ReceiveTimeout(received, time.Millisecond*10)
+	a.True(IsTemporary(err))
+	time.Sleep(time.Millisecond * 100)
+	a.NoError(fm.Send(fragmentTestData(8, 0)))
+	_, err = fm.ReceiveTimeout(received, time.Second)
+	a.Equal(ErrIncompleteMessage, errors.Cause(err))
+	l, err := fm.ReceiveTimeout(received, time.Second)
+	a.NoError(err)
+	a.Equal(8, l)
+}
+
+func TestFragmentedMessengerDropsExpiredWithoutFragments(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DestroyFastMq(testMqName))
+	mq, err := CreateFastMq(testMqName, 0, 0666, 16, 32)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(mq.Destroy())
+	}()
+	fm, err := NewFragmentedMessenger(mq, 0)
+	if !a.NoError(err) {
+		return
+	}
+	fm.SetReassemblyTimeout(time.Millisecond * 50)
+	var first []byte
+	a.NoError(fm.send(fragmentTestData(40, 0), func(f []byte) error {
+		if first == nil {
+			first = append([]byte(nil), f...)
+		}
+		return nil
+	}))
+	a.NoError(mq.Send(first))
+	received := make([]byte, 64)
+	_, err = fm.ReceiveTimeout(received, time.Millisecond*10)
+	a.True(IsTemporary(err))
+	a.Len(fm.pending, 1)
+	time.Sleep(time.Millisecond * 100)
+	// the queue is empty, but the stale message must be dropped anyway.
+	_, err = fm.ReceiveTimeout(received, time.Millisecond*10)
+	a.Equal(ErrIncompleteMessage, errors.Cause(err))
+	a.Len(fm.pending, 0)
+}
+
+func TestFragmentedMessengerInvalidHeaders(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DestroyFastMq(testMqName))
+	mq, err := CreateFastMq(testMqName, 0, 0666, 16, 32)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(mq.Destroy())
+	}()
+	fm, err := NewFragmentedMessenger(mq, 0)
+	if !a.NoError(err) {
+		return
+	}
+	a.Error(fm.SetMaxMessageSize(0))
+	a.NoError(fm.SetMaxMessageSize(64))
+	a.Error(fm.Send(fragmentTestData(65, 0)))
+	payload := fragmentTestData(32-fragmentHdrSize, 0)
+	// 64 bytes fit into 4 fragments of 16 bytes.
+	a.NoError(fm.addFragment(fragmentHdr{id: 1, index: 0, count: 4}, payload))
+	a.Error(fm.addFragment(fragmentHdr{id: 2, index: 0, count: 5}, payload))
+	a.Error(fm.addFragment(fragmentHdr{id: 3, index: 0, count: 0xFFFFFFFF}, payload))
+	a.Error(fm.addFragment(fragmentHdr{id: 4, index: 4, count: 4}, payload))
+	// a short fragment may only be the last one.
+	a.Error(fm.addFragment(fragmentHdr{id: 5, index: 0, count: 2}, payload[:1]))
+	a.NoError(fm.addFragment(fragmentHdr{id: 5, index: 1, count: 2}, payload[:1]))
+	a.Len(fm.pending, 2)
+}
@@ -30,6 +30,7 @@ var (
 	_ Messenger         = (*FastMq)(nil)
 	_ TimedMessenger    = (*FastMq)(nil)
 	_ PriorityMessenger = (*FastMq)(nil)
+	_ SizeLimited       = (*FastMq)(nil)
 )
 
 var (
@@ -264,6 +265,11 @@ func (mq *FastMq) Cap() int {
 	return mq.impl.heap.maxSize()
 }
 
+// MaxMsgSize returns max message size of the mq.
+func (mq *FastMq) MaxMsgSize() int {
+	return mq.impl.heap.maxMsgSize()
+}
+
 // SetBlocking sets whether the send/receive operations on the queue block.
 // This applies to the current instance only.
 func (mq *FastMq) SetBlocking(block bool) error {
@@ -315,6 +321,11 @@ func (mq *FastMq) Empty() bool {
 	return mq.impl.heap.safeLen() == 0
 }
 
+// Len returns the number of messages in the queue.
+func (mq *FastMq) Len() int {
+	return mq.impl.heap.safeLen()
+}
+
 func (mq *FastMq) doReceiveWait(timeout time.Duration) bool {
 	mq.locker.Unlock()
 	for i := 0; i < waitSpinsCount; i++ {
@@ -9,6 +9,7 @@ import (
 
 	"github.com/aybabtme/go-ipc/internal/allocator"
 	"github.com/aybabtme/go-ipc/internal/common"
+	"github.com/aybabtme/go-ipc/namespace"
 
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
@@ -27,6 +28,7 @@ var (
 	_ Messenger         = (*LinuxMessageQueue)(nil)
 	_ TimedMessenger    = (*LinuxMessageQueue)(nil)
 	_ PriorityMessenger = (*LinuxMessageQueue)(nil)
+	_ SizeLimited       = (*LinuxMessageQueue)(nil)
 )
 
 // LinuxMessageQueue is a linux-specific ipc mechanism based on message passing.
@@ -65,7 +67,7 @@ func CreateLinuxMessageQueue(name string, flag int, perm os.FileMode, maxQueueSi
 		sysflags |= unix.O_EXCL
 	}
 	attrs := &linuxMqAttr{Maxmsg: maxQueueSize, Msgsize: maxMsgSize}
-	id, err := mq_open(name, sysflags, uint32(perm), attrs)
+	id, err := mq_open(namespace.Name(name), sysflags, uint32(perm), attrs)
 	if err != nil {
 		return nil, errors.Wrap(err, "mq_open failed")
 	}
@@ -88,7 +90,7 @@ func CreateLinuxMessageQueue(name string, flag int, perm os.FileMode, maxQueueSi
 //		O_RDWR
 //			Open the queue to both send and receive messages.
 func OpenLinuxMessageQueue(name string, flag int) (*LinuxMessageQueue, error) {
-	id, err := mq_open(name, common.FlagsForAccess(flag)|unix.O_CLOEXEC, uint32(0), nil)
+	id, err := mq_open(namespace.Name(name), common.FlagsForAccess(flag)|unix.O_CLOEXEC, uint32(0), nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "mq_open failed")
 	}
@@ -232,6 +234,15 @@ func (mq *LinuxMessageQueue) Cap() int {
 	return attrs.Maxmsg
 }
 
+// MaxMsgSize returns max message size of the queue.
+func (mq *LinuxMessageQueue) MaxMsgSize() int {
+	attrs, err := mq.getAttrs()
+	if err != nil {
+		return len(mq.inputBuff)
+	}
+	return attrs.Msgsize
+}
+
 // SetBlocking sets whether the send/receive operations on the queue block.
 // This applies to the current instance only.
 func (mq *LinuxMessageQueue) SetBlocking(block bool) error {
@@ -308,7 +319,7 @@ func (mq *LinuxMessageQueue) getAttrs() (*linuxMqAttr, error) {
 
 // DestroyLinuxMessageQueue removes the queue permanently.
 func DestroyLinuxMessageQueue(name string) error {
-	err := mq_unlink(name)
+	err := mq_unlink(namespace.Name(name))
 	if err != nil {
 		if os.IsNotExist(err) {
 			err = nil
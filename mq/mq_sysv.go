@@ -45,19 +45,53 @@ func CreateSystemVMessageQueue(name string, flag int, perm os.FileMode) (*System
 	if !checkMqPerm(perm) {
 		return nil, errors.New("invalid mq permissions")
 	}
-	k, err := common.KeyForName(name)
+	var id int
+	_, _, err := common.OpenOrCreateForName(name, func(k common.Key, create bool) (err error) {
+		id, err = openOrCreateMsgget(k, create, perm)
+		return
+	}, os.O_CREATE|flag&os.O_EXCL)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to generate a key")
+		return nil, errors.Wrap(err, "msgget failed")
 	}
-	sysFlags := int(perm) | common.IpcCreate
-	if flag&os.O_EXCL != 0 {
-		sysFlags |= common.IpcExcl
+	return &SystemVMessageQueue{id: id, flags: flag, name: name}, nil
+}
+
+// CreateSystemVMessageQueueKey creates new queue with the given key and permissions.
+// Unlike CreateSystemVMessageQueue, it does not need to generate a key for a name,
+// which allows cooperating processes to agree on the key explicitly.
+//	key - System V ipc key. It must not be 0 (IPC_PRIVATE).
+//	flag - flag is a combination of os.O_EXCL and O_NONBLOCK.
+//	perm - object's permission bits.
+func CreateSystemVMessageQueueKey(key uint64, flag int, perm os.FileMode) (*SystemVMessageQueue, error) {
+	if !checkMqPerm(perm) {
+		return nil, errors.New("invalid mq permissions")
+	}
+	if key == 0 {
+		return nil, errors.New("invalid key")
 	}
-	id, err := msgget(k, sysFlags)
+	return createSystemVMessageQueue(common.Key(key), flag, perm)
+}
+
+func createSystemVMessageQueue(k common.Key, flag int, perm os.FileMode) (*SystemVMessageQueue, error) {
+	var id int
+	_, err := common.OpenOrCreate(func(create bool) (err error) {
+		id, err = openOrCreateMsgget(k, create, perm)
+		return
+	}, os.O_CREATE|flag&os.O_EXCL)
 	if err != nil {
 		return nil, errors.Wrap(err, "msgget failed")
 	}
-	return &SystemVMessageQueue{id: id, name: name, flags: flag}, nil
+	return &SystemVMessageQueue{id: id, flags: flag}, nil
+}
+
+// openOrCreateMsgget opens a queue with the given key, or creates it, if create is true.
+// It is a creator for common.OpenOrCreate.
+func openOrCreateMsgget(k common.Key, create bool, perm os.FileMode) (int, error) {
+	sysFlags := int(perm)
+	if create {
+		sysFlags |= common.IpcCreate | common.IpcExcl
+	}
+	return msgget(k, sysFlags)
 }
 
 // OpenSystemVMessageQueue opens existing message queue.
@@ -68,12 +102,27 @@ func OpenSystemVMessageQueue(name string, flags int) (*SystemVMessageQueue, erro
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to generate a key")
 	}
+	result, err := openSystemVMessageQueue(k, flags)
+	if err != nil {
+		return nil, err
+	}
+	result.name = name
+	return result, nil
+}
+
+// OpenSystemVMessageQueueKey opens existing message queue with the given key.
+//	key - System V ipc key.
+//	flag - 0 and O_NONBLOCK.
+func OpenSystemVMessageQueueKey(key uint64, flags int) (*SystemVMessageQueue, error) {
+	return openSystemVMessageQueue(common.Key(key), flags)
+}
+
+func openSystemVMessageQueue(k common.Key, flags int) (*SystemVMessageQueue, error) {
 	id, err := msgget(k, 0)
 	if err != nil {
 		return nil, errors.Wrap(err, "msgget failed")
 	}
-	result := &SystemVMessageQueue{id: id, name: name, flags: flags}
-	return result, nil
+	return &SystemVMessageQueue{id: id, flags: flags}, nil
 }
 
 // Send sends a message. It blocks if the queue is full.
@@ -107,18 +156,13 @@ func (mq *SystemVMessageQueue) Destroy() error {
 		return errors.Wrap(err, "mq close failed")
 	}
 	err := msgctl(mq.id, common.IpcRmid, nil)
-	if err == nil {
-		if err = os.Remove(common.TmpFilename(mq.name)); os.IsNotExist(err) {
-			err = nil
-		} else {
-			err = errors.Wrap(err, "failed to remove temporary file")
-		}
-	} else if os.IsNotExist(err) {
-		err = nil
-	} else {
-		err = errors.Wrap(err, "msgctl failed")
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "msgctl failed")
 	}
-	return err
+	if len(mq.name) > 0 {
+		return errors.Wrap(common.ReleaseKey(mq.name), "failed to release the key")
+	}
+	return nil
 }
 
 // Close closes the queue.
@@ -143,7 +187,8 @@ func DestroySystemVMessageQueue(name string) error {
 	mq, err := OpenSystemVMessageQueue(name, 0)
 	if err != nil {
 		if os.IsNotExist(errors.Cause(err)) {
-			err = nil
+			// the key file, or the registry entry may have outlived the queue.
+			err = errors.Wrap(common.ReleaseKey(name), "failed to release the key")
 		} else {
 			err = errors.Wrap(err, "open mq")
 		}
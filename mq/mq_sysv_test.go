@@ -5,8 +5,18 @@
 package mq
 
 import (
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/aybabtme/go-ipc/internal/common"
+	"github.com/aybabtme/go-ipc/namespace"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 )
 
 func sysVMqCtor(name string, flag int, perm os.FileMode) (Messenger, error) {
@@ -64,3 +74,173 @@ func TestSysVMqReceiveNonBlock(t *testing.T) {
 func TestSysVMqReceiveFromAnotherProcess(t *testing.T) {
 	testMqReceiveFromAnotherProcess(t, sysVMqCtor, sysVMqDtor, "sysv")
 }
+
+func TestSysVMqKeyHash(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-ipc-keys")
+	if !a.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	if !a.NoError(namespace.Set(namespace.Namespace{KeyDir: dir, KeyStrategy: namespace.KeyHash})) {
+		return
+	}
+	defer namespace.Reset()
+	a.NoError(DestroySystemVMessageQueue(testMqName))
+	mq, err := CreateSystemVMessageQueue(testMqName, os.O_EXCL, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	keys, err := common.RegisteredKeys()
+	a.NoError(err)
+	a.Contains(keys, testMqName)
+	_, err = os.Stat(filepath.Join(dir, testMqName))
+	a.True(os.IsNotExist(err))
+	mq2, err := OpenSystemVMessageQueue(testMqName, 0)
+	if a.NoError(err) {
+		a.NoError(mq.Send([]byte{1, 2, 3}))
+		data := make([]byte, 3)
+		_, err = mq2.Receive(data)
+		a.NoError(err)
+		a.Equal([]byte{1, 2, 3}, data)
+		a.NoError(mq2.Close())
+	}
+	a.NoError(mq.Destroy())
+	keys, err = common.RegisteredKeys()
+	a.NoError(err)
+	a.NotContains(keys, testMqName)
+}
+
+func TestSysVMqKeyHashDifferentKeyDirs(t *testing.T) {
+	a := assert.New(t)
+	dir1, err := ioutil.TempDir("", "go-ipc-keys")
+	if !a.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir1)
+	dir2, err := ioutil.TempDir("", "go-ipc-keys")
+	if !a.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir2)
+	defer namespace.Reset()
+	if !a.NoError(namespace.Set(namespace.Namespace{KeyDir: dir1, KeyStrategy: namespace.KeyHash})) {
+		return
+	}
+	a.NoError(DestroySystemVMessageQueue(testMqName))
+	mq, err := CreateSystemVMessageQueue(testMqName, os.O_EXCL, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	defer mq.Destroy()
+	// the name is not in the second registry, so its hash key is used.
+	if !a.NoError(namespace.Set(namespace.Namespace{KeyDir: dir2, KeyStrategy: namespace.KeyHash})) {
+		return
+	}
+	_, err = CreateSystemVMessageQueue(testMqName, os.O_EXCL, 0666)
+	a.True(os.IsExist(errors.Cause(err)))
+	for _, open := range []func() (*SystemVMessageQueue, error){
+		func() (*SystemVMessageQueue, error) { return OpenSystemVMessageQueue(testMqName, 0) },
+		func() (*SystemVMessageQueue, error) { return CreateSystemVMessageQueue(testMqName, 0, 0666) },
+	} {
+		mq2, err := open()
+		if !a.NoError(err) {
+			continue
+		}
+		a.NoError(mq.Send([]byte{1}))
+		data := make([]byte, 1)
+		_, err = mq2.Receive(data)
+		a.NoError(err)
+		a.Equal([]byte{1}, data)
+		a.NoError(mq2.Close())
+	}
+	keys, err := common.RegisteredKeys()
+	a.NoError(err)
+	a.Contains(keys, testMqName)
+}
+
+func TestSysVMqKeyHashCollision(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-ipc-keys")
+	if !a.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	if !a.NoError(namespace.Set(namespace.Namespace{KeyDir: dir, KeyStrategy: namespace.KeyHash})) {
+		return
+	}
+	defer namespace.Reset()
+	a.NoError(DestroySystemVMessageQueue(testMqName))
+	// neither open, nor destroy of a nonexistent queue must register a key.
+	_, err = OpenSystemVMessageQueue(testMqName, 0)
+	a.True(os.IsNotExist(errors.Cause(err)))
+	keys, err := common.RegisteredKeys()
+	a.NoError(err)
+	a.NotContains(keys, testMqName)
+	// the key, which is the hash of the name, is registered for another name.
+	h := fnv.New32a()
+	h.Write([]byte(testMqName))
+	hashKey := uint64(h.Sum32()%0x7FFFFFFF + 1)
+	foreign, err := CreateSystemVMessageQueueKey(hashKey, os.O_EXCL, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	defer foreign.Destroy()
+	registry := fmt.Sprintf("%d %s\n", hashKey, "go-ipc-other-mq")
+	if !a.NoError(ioutil.WriteFile(common.KeyRegistryPath(), []byte(registry), 0666)) {
+		return
+	}
+	_, err = OpenSystemVMessageQueue(testMqName, 0)
+	a.True(os.IsNotExist(errors.Cause(err)))
+	mq, err := CreateSystemVMessageQueue(testMqName, os.O_EXCL|O_NONBLOCK, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	keys, err = common.RegisteredKeys()
+	a.NoError(err)
+	if a.Contains(keys, testMqName) {
+		a.NotEqual(common.Key(hashKey), keys[testMqName])
+	}
+	a.NoError(foreign.Send([]byte{1}))
+	_, err = mq.Receive(make([]byte, 1))
+	a.Error(err)
+	a.NoError(mq.Destroy())
+}
+
+func TestSysVMqKey(t *testing.T) {
+	const key = 0x1eed0042
+	a := assert.New(t)
+	mq, err := CreateSystemVMessageQueueKey(key, os.O_EXCL, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	mq2, err := OpenSystemVMessageQueueKey(key, 0)
+	if a.NoError(err) {
+		a.NoError(mq2.Send([]byte{1}))
+		data := make([]byte, 1)
+		_, err = mq.Receive(data)
+		a.NoError(err)
+		a.Equal([]byte{1}, data)
+	}
+	a.NoError(mq.Destroy())
+	_, err = OpenSystemVMessageQueueKey(key, 0)
+	a.Error(err)
+	_, err = CreateSystemVMessageQueueKey(0, 0, 0666)
+	a.Error(err)
+}
+
+func TestSysVMqDestroyRemovesKeyFile(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-ipc-keys")
+	if !a.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	if !a.NoError(namespace.Set(namespace.Namespace{KeyDir: dir})) {
+		return
+	}
+	defer namespace.Reset()
+	a.NoError(DestroySystemVMessageQueue(testMqName))
+	_, err = os.Stat(filepath.Join(dir, testMqName))
+	a.True(os.IsNotExist(err))
+}
@@ -0,0 +1,175 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package mq
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	streamData = byte(iota)
+	streamEnd
+)
+
+const (
+	// stream message header: message type and sequence number.
+	streamHdrSize = 5
+	// maxOutOfOrderMessages is the max number of messages, which may be received ahead of their turn.
+	maxOutOfOrderMessages = 1024
+)
+
+// this is to ensure, that Stream satisfies io interfaces.
+var (
+	_ io.ReadWriteCloser = (*Stream)(nil)
+)
+
+// Stream is a byte stream on top of a Messenger.
+// Writes are split into messages of up to max message size of the messenger.
+// A message, which was partially consumed by Read, is buffered until the next Read.
+// The end of the stream is marked by a special message, after which Read returns io.EOF.
+// Messages are numbered, so the stream is restored correctly even if the messenger
+// reorders messages of the same priority, as FastMq may do.
+// There must be one writer and one reader for a queue.
+// A Stream is not safe for concurrent use.
+type Stream struct {
+	mq         Messenger
+	sendBuff   []byte
+	recvBuff   []byte
+	pending    []byte
+	outOfOrder map[uint32][]byte
+	sendSeq    uint32
+	recvSeq    uint32
+	read       bool
+	writeEnd   bool
+	readEnd    bool
+}
+
+// NewStream returns new Stream on top of the given messenger.
+// If the messenger is SizeLimited, message size is its max message size,
+// otherwise, it is 8192 bytes.
+func NewStream(mq Messenger) *Stream {
+	size, ok := maxMsgSize(mq)
+	if !ok {
+		size = defaultMsgBufferSize
+	}
+	return &Stream{
+		mq:         mq,
+		sendBuff:   make([]byte, size),
+		recvBuff:   make([]byte, size),
+		outOfOrder: make(map[uint32][]byte),
+	}
+}
+
+// Read reads data from the stream. It blocks if the queue is empty.
+// It returns io.EOF, when the end of the stream was received.
+func (s *Stream) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.readEnd {
+			return 0, io.EOF
+		}
+		s.read = true
+		msg, err := s.nextMessage()
+		if err != nil {
+			return 0, err
+		}
+		switch msg[0] {
+		case streamData:
+			s.pending = msg[streamHdrSize:]
+		case streamEnd:
+			s.readEnd = true
+		default:
+			return 0, errors.Errorf("invalid stream message type %d", msg[0])
+		}
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// nextMessage returns the message with the next sequence number.
+// Messages received out of order are saved until their turn.
+func (s *Stream) nextMessage() ([]byte, error) {
+	for {
+		if msg, found := s.outOfOrder[s.recvSeq]; found {
+			delete(s.outOfOrder, s.recvSeq)
+			s.recvSeq++
+			return msg, nil
+		}
+		l, err := s.mq.Receive(s.recvBuff)
+		if err != nil {
+			return nil, err
+		}
+		if l < streamHdrSize {
+			return nil, errors.Errorf("invalid stream message of %d bytes", l)
+		}
+		seq := binary.LittleEndian.Uint32(s.recvBuff[1:])
+		if seq == s.recvSeq {
+			s.recvSeq++
+			return s.recvBuff[:l], nil
+		}
+		if len(s.outOfOrder) >= maxOutOfOrderMessages {
+			return nil, errors.Errorf("too many out of order messages, expected message %d", s.recvSeq)
+		}
+		msg := make([]byte, l)
+		copy(msg, s.recvBuff)
+		s.outOfOrder[seq] = msg
+	}
+}
+
+// Write splits the data into messages and sends them. It blocks if the queue is full.
+func (s *Stream) Write(p []byte) (int, error) {
+	if s.writeEnd {
+		return 0, errors.New("write to a closed stream")
+	}
+	if len(s.sendBuff) <= streamHdrSize {
+		return 0, errors.New("max message size of the messenger is too small")
+	}
+	var written int
+	for written < len(p) {
+		l := copy(s.sendBuff[streamHdrSize:], p[written:])
+		if err := s.send(streamData, l); err != nil {
+			return written, err
+		}
+		written += l
+	}
+	return written, nil
+}
+
+// CloseWrite sends the end of the stream marker. Subsequent writes will fail.
+func (s *Stream) CloseWrite() error {
+	if s.writeEnd {
+		return nil
+	}
+	s.writeEnd = true
+	if err := s.send(streamEnd, 0); err != nil {
+		return errors.Wrap(err, "failed to send the end of the stream")
+	}
+	return nil
+}
+
+// Close sends the end of the stream marker, unless the stream has been read from,
+// and closes the messenger. This way the reader gets io.EOF, even if nothing was written.
+func (s *Stream) Close() error {
+	var err error
+	if !s.read {
+		err = s.CloseWrite()
+	}
+	if errClose := s.mq.Close(); errClose != nil {
+		return errors.Wrap(errClose, "failed to close the messenger")
+	}
+	return err
+}
+
+// send sends a message of the given type with 'size' bytes of payload, which is already in sendBuff.
+func (s *Stream) send(typ byte, size int) error {
+	s.sendBuff[0] = typ
+	binary.LittleEndian.PutUint32(s.sendBuff[1:], s.sendSeq)
+	if err := s.mq.Send(s.sendBuff[:streamHdrSize+size]); err != nil {
+		return err
+	}
+	s.sendSeq++
+	return nil
+}
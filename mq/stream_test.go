@@ -0,0 +1,116 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package mq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamCopy(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DestroyFastMq(testMqName))
+	mq, err := CreateFastMq(testMqName, 0, 0666, 4, 128)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(DestroyFastMq(testMqName))
+	}()
+	mqr, err := OpenFastMq(testMqName, 0)
+	if !a.NoError(err) {
+		return
+	}
+	data := fragmentTestData(10000, 3)
+	writer, reader := NewStream(mq), NewStream(mqr)
+	go func() {
+		_, err := io.Copy(writer, bytes.NewReader(data))
+		a.NoError(err)
+		a.NoError(writer.Close())
+	}()
+	received, err := ioutil.ReadAll(reader)
+	a.NoError(err)
+	a.Equal(data, received)
+	n, err := reader.Read(make([]byte, 1))
+	a.Equal(0, n)
+	a.Equal(io.EOF, err)
+	a.NoError(reader.Close())
+}
+
+func TestStreamPartialRead(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DestroyFastMq(testMqName))
+	mq, err := CreateFastMq(testMqName, 0, 0666, 4, 128)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(DestroyFastMq(testMqName))
+	}()
+	s := NewStream(mq)
+	_, err = s.Write([]byte("hello, world"))
+	a.NoError(err)
+	a.NoError(s.CloseWrite())
+	_, err = s.Write([]byte("!"))
+	a.Error(err)
+	buff := make([]byte, 5)
+	n, err := s.Read(buff)
+	a.NoError(err)
+	a.Equal("hello", string(buff[:n]))
+	rest, err := ioutil.ReadAll(s)
+	a.NoError(err)
+	a.Equal(", world", string(rest))
+	a.NoError(s.Close())
+}
+
+func TestStreamCloseWithoutWrites(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DestroyFastMq(testMqName))
+	mq, err := CreateFastMq(testMqName, 0, 0666, 4, 128)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(DestroyFastMq(testMqName))
+	}()
+	mqr, err := OpenFastMq(testMqName, 0)
+	if !a.NoError(err) {
+		return
+	}
+	a.NoError(NewStream(mq).Close())
+	reader := NewStream(mqr)
+	received, err := ioutil.ReadAll(reader)
+	a.NoError(err)
+	a.Empty(received)
+	a.NoError(reader.Close())
+}
+
+func TestStreamTooManyOutOfOrder(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DestroyFastMq(testMqName))
+	mq, err := CreateFastMq(testMqName, 0, 0666, maxOutOfOrderMessages+1, 16)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(DestroyFastMq(testMqName))
+	}()
+	// message 0 is never sent.
+	msg := make([]byte, streamHdrSize)
+	for seq := 1; seq <= maxOutOfOrderMessages+1; seq++ {
+		msg[0] = streamData
+		binary.LittleEndian.PutUint32(msg[1:], uint32(seq))
+		if !a.NoError(mq.Send(msg)) {
+			return
+		}
+	}
+	s := NewStream(mq)
+	_, err = s.Read(make([]byte, 1))
+	a.Error(err)
+	a.NoError(mq.Close())
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// Package namespace allows to isolate named ipc objects of different applications,
+// tests, or tenants, which run on the same machine.
+// A namespace is process-wide. It defines a prefix, which is added to the names of all objects,
+// and directories, where the objects backed by files are created.
+// It is honoured by shm, sync, mq, and fifo packages, so all the processes, which
+// share some objects, must use the same namespace.
+// The namespace must be set before any object is created or opened, and must not be changed after that.
+package namespace
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// KeyStrategy defines, how System V keys are generated for object names.
+type KeyStrategy int
+
+const (
+	// KeyFile strategy creates an empty file for every object name in KeyDir and uses its ftok value as a key.
+	// It is the default strategy.
+	KeyFile KeyStrategy = iota
+	// KeyHash strategy uses a hash of the object name as a key. Keys are stored in a registry file in KeyDir,
+	// which is locked with flock, so that the collisions between the names are resolved.
+	// A name, which is not in the registry, resolves to its hash value, so processes, which use
+	// different key directories, share the object, unless a collision is recorded in one of the registries.
+	// In the latter case the next free key is used, and the processes must use the same key directory.
+	KeyHash
+)
+
+// Namespace describes object names and locations.
+// Empty fields mean default values.
+type Namespace struct {
+	// Prefix is added to the name of every object. It must not contain '/' or '\'.
+	Prefix string
+	// ShmDir is a directory, where shared memory objects are created.
+	// It is used on linux and windows, on darwin and freebsd shared memory objects are not files.
+	// By default, it is /dev/shm, or the first tmpfs found in /proc/mounts on linux,
+	// and %TEMP%/go-ipc on windows.
+	ShmDir string
+	// KeyDir is a directory, where files for System V keys are created. By default, it is os.TempDir().
+	KeyDir string
+	// FifoDir is a directory, where unix fifos are created. By default, it is /tmp.
+	FifoDir string
+	// KeyStrategy selects, how System V keys are generated. By default, it is KeyFile.
+	KeyStrategy KeyStrategy
+}
+
+var (
+	mut     sync.RWMutex
+	current Namespace
+)
+
+// Set sets the namespace for the current process.
+func Set(ns Namespace) error {
+	if strings.ContainsAny(ns.Prefix, `/\`) {
+		return errors.New("namespace prefix must not contain path separators")
+	}
+	mut.Lock()
+	current = ns
+	mut.Unlock()
+	return nil
+}
+
+// Reset restores the default namespace.
+func Reset() {
+	mut.Lock()
+	current = Namespace{}
+	mut.Unlock()
+}
+
+// Current returns the namespace of the current process.
+func Current() Namespace {
+	mut.RLock()
+	defer mut.RUnlock()
+	return current
+}
+
+// Name returns the name of an object with the given name in the current namespace.
+func Name(name string) string {
+	return Current().Prefix + name
+}
+
+// Dir returns dir, if it is not empty, and def otherwise.
+// It is a helper to resolve namespace directories.
+func Dir(dir, def string) string {
+	if len(dir) == 0 {
+		return def
+	}
+	return dir
+}
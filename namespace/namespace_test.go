@@ -0,0 +1,23 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package namespace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespace(t *testing.T) {
+	a := assert.New(t)
+	defer Reset()
+	a.Equal("obj", Name("obj"))
+	a.Error(Set(Namespace{Prefix: "a/b"}))
+	a.Error(Set(Namespace{Prefix: `a\b`}))
+	a.NoError(Set(Namespace{Prefix: "test.", ShmDir: "/tmp/shm"}))
+	a.Equal("test.obj", Name("obj"))
+	a.Equal("/tmp/shm", Dir(Current().ShmDir, "/dev/shm"))
+	a.Equal("/tmp", Dir(Current().KeyDir, "/tmp"))
+	Reset()
+	a.Equal(Namespace{}, Current())
+}
@@ -41,11 +41,15 @@ func NewMemoryObject(name string, flag int, perm os.FileMode) (*MemoryObject, er
 	if err != nil {
 		return nil, err
 	}
+	return wrapMemoryObject(impl), nil
+}
+
+func wrapMemoryObject(impl *memoryObject) *MemoryObject {
 	result := &MemoryObject{impl}
 	runtime.SetFinalizer(impl, func(memObject *memoryObject) {
 		memObject.Close()
 	})
-	return result, nil
+	return result
 }
 
 // NewMemoryObjectSize opens or creates a shared memory object with the given name.
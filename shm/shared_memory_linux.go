@@ -12,6 +12,8 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/aybabtme/go-ipc/namespace"
+
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
 )
@@ -53,8 +55,12 @@ func shmOpen(path string, flag int, perm os.FileMode) (*os.File, error) {
 // glibc/sysdeps/posix/shm-directory.h
 func shmName(name string) (string, error) {
 	name = strings.TrimLeft(name, "/")
+	if len(name) == 0 {
+		return "", errors.New("invalid shm name")
+	}
+	name = namespace.Name(name)
 	nameLen := len(name)
-	if nameLen == 0 || nameLen >= maxNameLen || strings.Contains(name, "/") {
+	if nameLen >= maxNameLen || strings.Contains(name, "/") {
 		return "", errors.New("invalid shm name")
 	}
 	var dir string
@@ -66,6 +72,12 @@ func shmName(name string) (string, error) {
 }
 
 func shmDirectory() (string, error) {
+	if dir := namespace.Current().ShmDir; len(dir) > 0 {
+		if !strings.HasSuffix(dir, "/") {
+			dir += "/"
+		}
+		return dir, nil
+	}
 	shmPathOnce.Do(locateShmFs)
 	if len(shmPath) == 0 {
 		return shmPath, errors.New("error locating the shared memory path")
@@ -0,0 +1,137 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux
+
+package shm
+
+import (
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// Flags for anonymous memory objects.
+const (
+	// MFD_ALLOW_SEALING allows to seal the object.
+	MFD_ALLOW_SEALING = unix.MFD_ALLOW_SEALING
+	// MFD_HUGETLB creates the object in the hugetlbfs using default huge page size.
+	MFD_HUGETLB = unix.MFD_HUGETLB
+)
+
+const (
+	maxMemfdNameLen = 249
+)
+
+// NewAnonymousMemoryObject creates a new shared memory object, which has no name in the filesystem.
+// It is backed by memfd_create and is destroyed automatically,
+// when all its descriptors are closed and all its mappings are unmapped.
+// The object can be shared with other processes by sending its descriptor
+// with SendMemoryObject, or by passing it to a child process.
+//	name - a name of the object. It is used for debugging purposes only and does not have to be unique.
+//	flag - a combination of MFD_* flags.
+func NewAnonymousMemoryObject(name string, flag int) (*MemoryObject, error) {
+	if len(name) > maxMemfdNameLen {
+		return nil, errors.New("invalid anonymous object name")
+	}
+	fd, err := unix.MemfdCreate(name, flag|unix.MFD_CLOEXEC)
+	if err != nil {
+		return nil, errors.Wrap(os.NewSyscallError("memfd_create", err), "failed to create anonymous memory object")
+	}
+	return wrapMemoryObject(&memoryObject{file: os.NewFile(uintptr(fd), name), anonymous: true}), nil
+}
+
+// SendMemoryObject sends the object's descriptor and name over a unix socket.
+// The object remains open and can be closed after the call.
+func SendMemoryObject(conn *net.UnixConn, obj *MemoryObject) error {
+	name := obj.Name()
+	if len(name) > maxNameLen {
+		name = name[:maxNameLen]
+	}
+	// the payload can't be empty, as stream sockets do not pass control messages without data.
+	payload := append([]byte{byte(len(name))}, name...)
+	rights := unix.UnixRights(int(obj.Fd()))
+	n, oobn, err := conn.WriteMsgUnix(payload, rights, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to send memory object")
+	}
+	if n != len(payload) || oobn != len(rights) {
+		return errors.New("failed to send memory object: short write")
+	}
+	return nil
+}
+
+// ReceiveMemoryObject receives a memory object sent with SendMemoryObject.
+// The returned object is anonymous, as the name of the original object is known to the sender only.
+// Its Destroy is equivalent to Close.
+func ReceiveMemoryObject(conn *net.UnixConn) (*MemoryObject, error) {
+	payload := make([]byte, maxNameLen+1)
+	oob := make([]byte, unix.CmsgSpace(4))
+	n, oobn, flags, _, err := conn.ReadMsgUnix(payload, oob)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to receive memory object")
+	}
+	fds, err := parseRights(oob[:oobn])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to receive memory object")
+	}
+	if len(fds) != 1 || flags&unix.MSG_CTRUNC != 0 || n == 0 || int(payload[0]) > n-1 {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+		return nil, errors.New("failed to receive memory object: invalid message")
+	}
+	unix.CloseOnExec(fds[0])
+	name := string(payload[1 : 1+int(payload[0])])
+	return wrapMemoryObject(&memoryObject{file: os.NewFile(uintptr(fds[0]), name), anonymous: true}), nil
+}
+
+func parseRights(oob []byte) ([]int, error) {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, err
+	}
+	var result []int
+	for i := range msgs {
+		fds, err := unix.ParseUnixRights(&msgs[i])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, fds...)
+	}
+	return result, nil
+}
+
+// Seals for anonymous memory objects. See Seal.
+const (
+	// SEAL_SEAL prevents further sealing.
+	SEAL_SEAL = unix.F_SEAL_SEAL
+	// SEAL_SHRINK prevents the object from shrinking.
+	SEAL_SHRINK = unix.F_SEAL_SHRINK
+	// SEAL_GROW prevents the object from growing.
+	SEAL_GROW = unix.F_SEAL_GROW
+	// SEAL_WRITE prevents any modifications of the object's contents.
+	SEAL_WRITE = unix.F_SEAL_WRITE
+)
+
+// Seal adds seals to the object. seals is a combination of SEAL_* constants.
+// The object must have been created by NewAnonymousMemoryObject with MFD_ALLOW_SEALING flag.
+// Seals can't be removed, and they are visible to all the processes sharing the object.
+// SEAL_WRITE can't be added, if there are writable shared mappings of the object.
+// Once the object is write-sealed, mmf.NewMemoryRegion refuses to create writable shared mappings of it.
+func (obj *MemoryObject) Seal(seals int) error {
+	if _, err := unix.FcntlInt(obj.Fd(), unix.F_ADD_SEALS, seals); err != nil {
+		return errors.Wrap(os.NewSyscallError("fcntl", err), "failed to seal memory object")
+	}
+	return nil
+}
+
+// Seals returns current seals of the object as a combination of SEAL_* constants.
+func (obj *MemoryObject) Seals() (int, error) {
+	seals, err := unix.FcntlInt(obj.Fd(), unix.F_GET_SEALS, 0)
+	if err != nil {
+		return 0, errors.Wrap(os.NewSyscallError("fcntl", err), "failed to get memory object seals")
+	}
+	return seals, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package shm
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/aybabtme/go-ipc/mmf"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func unixConnPair() (*net.UnixConn, *net.UnixConn, error) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var conns [2]*net.UnixConn
+	for i, fd := range fds {
+		file := os.NewFile(uintptr(fd), "socketpair")
+		conn, err := net.FileConn(file)
+		file.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		conns[i] = conn.(*net.UnixConn)
+	}
+	return conns[0], conns[1], nil
+}
+
+func TestAnonymousMemoryObject(t *testing.T) {
+	a := assert.New(t)
+	obj, err := NewAnonymousMemoryObject(defaultObjectName, 0)
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal(defaultObjectName, obj.Name())
+	a.Equal(int64(0), obj.Size())
+	a.NoError(obj.Truncate(int64(len(shmTestData))))
+	a.Equal(int64(len(shmTestData)), obj.Size())
+	_, err = NewMemoryObject(defaultObjectName, os.O_RDONLY, 0666)
+	a.Error(err)
+	a.NoError(obj.Destroy())
+}
+
+func TestAnonymousMemoryObjectSendReceive(t *testing.T) {
+	a := assert.New(t)
+	obj, err := NewAnonymousMemoryObject(defaultObjectName, MFD_ALLOW_SEALING)
+	if !a.NoError(err) {
+		return
+	}
+	defer obj.Close()
+	a.NoError(obj.Truncate(int64(len(shmTestData))))
+	region, err := mmf.NewMemoryRegion(obj, mmf.MEM_READWRITE, 0, len(shmTestData))
+	if !a.NoError(err) {
+		return
+	}
+	defer region.Close()
+	copy(region.Data(), shmTestData)
+	c1, c2, err := unixConnPair()
+	if !a.NoError(err) {
+		return
+	}
+	defer c1.Close()
+	defer c2.Close()
+	if !a.NoError(SendMemoryObject(c1, obj)) {
+		return
+	}
+	received, err := ReceiveMemoryObject(c2)
+	if !a.NoError(err) {
+		return
+	}
+	defer received.Close()
+	a.Equal(defaultObjectName, received.Name())
+	a.Equal(int64(len(shmTestData)), received.Size())
+	region2, err := mmf.NewMemoryRegion(received, mmf.MEM_READ_ONLY, 0, len(shmTestData))
+	if !a.NoError(err) {
+		return
+	}
+	defer region2.Close()
+	a.Equal(shmTestData, region2.Data())
+	region.Data()[0] = ^shmTestData[0]
+	a.Equal(^shmTestData[0], region2.Data()[0])
+}
+
+func TestAnonymousMemoryObjectSeal(t *testing.T) {
+	a := assert.New(t)
+	obj, err := NewAnonymousMemoryObject(defaultObjectName, MFD_ALLOW_SEALING)
+	if !a.NoError(err) {
+		return
+	}
+	defer obj.Close()
+	a.NoError(obj.Truncate(int64(len(shmTestData))))
+	region, err := mmf.NewMemoryRegion(obj, mmf.MEM_READWRITE, 0, len(shmTestData))
+	if !a.NoError(err) {
+		return
+	}
+	copy(region.Data(), shmTestData)
+	// write seal is not allowed, while there are writable mappings.
+	a.Error(obj.Seal(SEAL_WRITE))
+	a.NoError(region.Close())
+	seals := SEAL_WRITE | SEAL_SHRINK | SEAL_GROW | SEAL_SEAL
+	if !a.NoError(obj.Seal(seals)) {
+		return
+	}
+	actual, err := obj.Seals()
+	a.NoError(err)
+	a.Equal(seals, actual)
+	_, err = mmf.NewMemoryRegion(obj, mmf.MEM_READWRITE, 0, len(shmTestData))
+	a.Error(err)
+	a.Error(obj.Truncate(int64(len(shmTestData) * 2)))
+	a.Error(obj.Truncate(0))
+	region, err = mmf.NewMemoryRegion(obj, mmf.MEM_READ_ONLY, 0, len(shmTestData))
+	if a.NoError(err) {
+		a.Equal(shmTestData, region.Data())
+		a.NoError(region.Close())
+	}
+	region, err = mmf.NewMemoryRegion(obj, mmf.MEM_COPY_ON_WRITE, 0, len(shmTestData))
+	if a.NoError(err) {
+		region.Data()[0] = ^shmTestData[0]
+		a.NoError(region.Close())
+	}
+}
+
+func TestAnonymousMemoryObjectSealNotAllowed(t *testing.T) {
+	a := assert.New(t)
+	obj, err := NewAnonymousMemoryObject(defaultObjectName, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer obj.Close()
+	a.Error(obj.Seal(SEAL_GROW))
+	seals, err := obj.Seals()
+	a.NoError(err)
+	a.Equal(SEAL_SEAL, seals)
+}
@@ -4,13 +4,16 @@ package shm
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 	"time"
 
 	testutil "github.com/aybabtme/go-ipc/internal/test"
 	"github.com/aybabtme/go-ipc/mmf"
+	"github.com/aybabtme/go-ipc/namespace"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -182,6 +185,35 @@ func TestMemoryObjectName(t *testing.T) {
 	}
 }
 
+func TestMemoryObjectNamespace(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-ipc-ns")
+	if !a.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	if !a.NoError(namespace.Set(namespace.Namespace{Prefix: "ns.", ShmDir: dir})) {
+		return
+	}
+	defer namespace.Reset()
+	obj, err := NewMemoryObject(defaultObjectName, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal(defaultObjectName, obj.Name())
+	// shared memory objects are files only on linux and windows.
+	checkFile := runtime.GOOS == "linux" || runtime.GOOS == "windows"
+	if checkFile {
+		_, err = os.Stat(filepath.Join(dir, "ns."+defaultObjectName))
+		a.NoError(err)
+	}
+	a.NoError(obj.Destroy())
+	if checkFile {
+		_, err = os.Stat(filepath.Join(dir, "ns."+defaultObjectName))
+		a.True(os.IsNotExist(err))
+	}
+}
+
 func TestIfRegionIsAliveAferObjectClose(t *testing.T) {
 	object, err := NewMemoryObject(defaultObjectName, os.O_CREATE|os.O_RDWR, 0666)
 	if !assert.NoError(t, err) {
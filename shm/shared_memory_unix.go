@@ -10,6 +10,8 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/aybabtme/go-ipc/namespace"
+
 	"github.com/pkg/errors"
 )
 
@@ -19,6 +21,8 @@ const (
 
 type memoryObject struct {
 	file *os.File
+	// anonymous objects have no name in the filesystem, so there is nothing to remove on Destroy.
+	anonymous bool
 }
 
 func newMemoryObject(name string, flag int, perm os.FileMode) (*memoryObject, error) {
@@ -39,6 +43,9 @@ func (obj *memoryObject) Destroy() error {
 			return errors.Wrap(err, "close failed")
 		}
 	}
+	if obj.anonymous {
+		return nil
+	}
 	if err := doDestroyMemoryObject(obj.file.Name()); err != nil {
 		return errors.Wrap(err, "unable to destroy memory object")
 	}
@@ -46,13 +53,16 @@ func (obj *memoryObject) Destroy() error {
 }
 
 func (obj *memoryObject) Name() string {
+	if obj.anonymous {
+		return obj.file.Name()
+	}
 	result := filepath.Base(obj.file.Name())
 	// on darwin we do this trick due to
 	// http://www.opensource.apple.com/source/Libc/Libc-320/sys/shm_open.c
 	if isDarwin {
 		result = result[:strings.LastIndex(result, "\t")]
 	}
-	return result
+	return strings.TrimPrefix(result, namespace.Current().Prefix)
 }
 
 func (obj *memoryObject) Close() error {
@@ -6,6 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+
+	"github.com/aybabtme/go-ipc/namespace"
 
 	"github.com/pkg/errors"
 )
@@ -38,7 +41,7 @@ func (obj *memoryObject) Destroy() error {
 }
 
 func (obj *memoryObject) Name() string {
-	return filepath.Base(obj.file.Name())
+	return strings.TrimPrefix(filepath.Base(obj.file.Name()), namespace.Current().Prefix)
 }
 
 func (obj *memoryObject) Close() error {
@@ -80,11 +83,11 @@ func shmName(name string) (string, error) {
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get tmp directory name")
 	}
-	return path + "/" + name, nil
+	return path + "/" + namespace.Name(name), nil
 }
 
 func sharedDirName() (string, error) {
-	rootPath := os.TempDir() + "/go-ipc"
+	rootPath := namespace.Dir(namespace.Current().ShmDir, os.TempDir()+"/go-ipc")
 	if err := os.Mkdir(rootPath, 0644); err != nil && !os.IsExist(err) {
 		return "", err
 	}
@@ -9,11 +9,13 @@ import (
 )
 
 var (
-	// MaxCondWaiters is the maximum length of the waiting queue for this type of a cond.
-	// This limit is actual for waitlist-based condvars, currently on windows and darwin.
-	// If this limit is exceeded, Wait/WaitTimeout will panic with ErrTooManyWaiters.
+	// MaxCondWaiters was the maximum length of the waiting queue for waitlist-based condvars.
+	//
+	// Deprecated: the number of waiters is not limited any more. The value is kept for compatibility.
 	MaxCondWaiters = 128
 	// ErrTooManyWaiters is an error, that indicates, that the waiting queue is full.
+	//
+	// Deprecated: it is not returned any more.
 	ErrTooManyWaiters = errors.New("waiters limit has been reached")
 )
 
@@ -33,23 +35,56 @@ func NewCond(name string, flag int, perm os.FileMode, l IPCLocker) (*Cond, error
 	return (*Cond)(c), nil
 }
 
-// Signal wakes one waiter.
+// Signal wakes one waiter. It panics on an error.
 func (c *Cond) Signal() {
-	(*cond)(c).signal()
+	if err := c.SignalErr(); err != nil {
+		panic(err)
+	}
+}
+
+// SignalErr wakes one waiter. It returns an error, if the operation failed.
+func (c *Cond) SignalErr() error {
+	return (*cond)(c).signal()
 }
 
-// Broadcast wakes all waiters.
+// Broadcast wakes all waiters. It panics on an error.
 func (c *Cond) Broadcast() {
-	(*cond)(c).broadcast()
+	if err := c.BroadcastErr(); err != nil {
+		panic(err)
+	}
 }
 
-// Wait waits for the condvar to be signaled.
+// BroadcastErr wakes all waiters. It returns an error, if the operation failed.
+func (c *Cond) BroadcastErr() error {
+	return (*cond)(c).broadcast()
+}
+
+// Wait waits for the condvar to be signaled. It panics on an error.
 func (c *Cond) Wait() {
-	(*cond)(c).wait()
+	if err := c.WaitErr(); err != nil {
+		panic(err)
+	}
 }
 
-// WaitTimeout waits for the condvar to be signaled for not longer, than timeout.
+// WaitErr waits for the condvar to be signaled. It returns an error, if the operation failed.
+// If the locker implements FallibleLocker, it is unlocked and locked with UnlockErr and LockErr.
+// If unlocking or relocking the locker fails, its state is undefined, otherwise it is locked on return.
+func (c *Cond) WaitErr() error {
+	return (*cond)(c).wait()
+}
+
+// WaitTimeout waits for the condvar to be signaled for not longer, than timeout. It panics on an error.
 func (c *Cond) WaitTimeout(timeout time.Duration) bool {
+	result, err := c.WaitTimeoutErr(timeout)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// WaitTimeoutErr waits for the condvar to be signaled for not longer, than timeout.
+// It returns false and a nil error, if the timeout has elapsed. See WaitErr for error handling details.
+func (c *Cond) WaitTimeoutErr(timeout time.Duration) (bool, error) {
 	return (*cond)(c).waitTimeout(timeout)
 }
 
@@ -3,77 +3,63 @@
 package sync
 
 import (
-	"fmt"
 	"os"
-	"sync/atomic"
+	"strconv"
 	"time"
-	"unsafe"
 
 	"github.com/pkg/errors"
 )
 
-const (
-	condWaiterSize = 8
-)
-
-var (
-	pid = os.Getpid()
-	seq uint32
-)
-
 type waiter struct {
-	id *uint64
-	e  *Event
+	ticket uint64
+	e      *Event
 }
 
-func nextID() uint32 {
-	return atomic.AddUint32(&seq, 1)
-}
-
-func newWaiter(ptr unsafe.Pointer) *waiter {
-	for {
-		id := uint64(pid)<<32 | uint64(nextID())
-		e, err := NewEvent(condWaiterEventName(id), os.O_CREATE|os.O_EXCL, 0666, false)
-		if err == nil {
-			result := &waiter{id: (*uint64)(ptr), e: e}
-			*result.id = id
-			return result
-		}
-		if !os.IsExist(errors.Cause(err)) {
-			panic(errors.Wrap(err, "cond: failed to create an event"))
+// newWaiter creates an event for the waiter with the given ticket.
+// The ticket is not used by any live waiter of the cond, and cond ids are random 64-bit numbers,
+// so an event, which exists with the same name, is a leftover of a crashed process, and it is replaced.
+func newWaiter(condID uint64, ticket uint64) (*waiter, error) {
+	name := condWaiterEventName(condID, ticket)
+	e, err := NewEvent(name, os.O_CREATE|os.O_EXCL, 0666, false)
+	if err != nil && os.IsExist(errors.Cause(err)) {
+		if err = DestroyEvent(name); err == nil {
+			e, err = NewEvent(name, os.O_CREATE|os.O_EXCL, 0666, false)
 		}
 	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cond: failed to create an event")
+	}
+	return &waiter{ticket: ticket, e: e}, nil
 }
 
-func openWaiter(ptr unsafe.Pointer) *waiter {
-	return &waiter{id: (*uint64)(ptr)}
-}
-
-func (w *waiter) signal() bool {
-	ev, err := NewEvent(condWaiterEventName(*w.id), 0, 0, false)
+// signalWaiter sets the event of the waiter with the given ticket.
+// It returns false, if the waiter does not exist.
+func signalWaiter(condID uint64, ticket uint64) (bool, error) {
+	ev, err := NewEvent(condWaiterEventName(condID, ticket), 0, 0, false)
 	if err != nil {
 		if os.IsNotExist(errors.Cause(err)) {
-			return false
+			return false, nil
 		}
-		panic(err)
+		return false, err
 	}
-	ev.Set()
+	err = ev.SetErr()
 	ev.Close()
-	return true
-}
-
-func (w *waiter) isSame(ptr unsafe.Pointer) bool {
-	return unsafe.Pointer(w.id) == ptr
+	if err != nil {
+		return false, errors.Wrap(err, "cond: failed to set an event")
+	}
+	return true, nil
 }
 
 func (w *waiter) destroy() {
 	w.e.Destroy()
 }
 
-func (w *waiter) waitTimeout(timeout time.Duration) bool {
-	return w.e.WaitTimeout(timeout)
+func (w *waiter) waitTimeout(timeout time.Duration) (bool, error) {
+	return w.e.WaitTimeoutErr(timeout)
 }
 
-func condWaiterEventName(id uint64) string {
-	return fmt.Sprintf("cev.%d", id)
+// condWaiterEventName returns the name of the waiter's event.
+// It is kept short, as darwin limits shared memory names to 31 characters.
+func condWaiterEventName(condID uint64, ticket uint64) string {
+	return "cev." + strconv.FormatUint(condID, 36) + "." + strconv.FormatUint(ticket, 36)
 }
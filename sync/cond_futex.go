@@ -1,6 +1,6 @@
 // Copyright 2016 Aleksandr Demakin. All rights reserved.
 
-//+build freebsd linux
+//+build freebsd linux,!waitlist_cond_linux
 
 package sync
 
@@ -16,6 +16,11 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	// CondStateSize is the size of the memory needed by NewCondAt.
+	CondStateSize = 4
+)
+
 // cond is a futex-based convar.
 type cond struct {
 	L      IPCLocker
@@ -44,45 +49,57 @@ func newCond(name string, flag int, perm os.FileMode, l IPCLocker) (*cond, error
 	return result, nil
 }
 
-func (c *cond) signal() {
+// NewCondAt creates a condvar, which state is stored in the given memory.
+// The caller must keep the memory mapped, while the condvar is in use.
+// Close and Destroy are no-op for such condvars.
+//	mem - at least CondStateSize bytes, 4-byte aligned.
+//	l - a locker, associated with the shared resource.
+func NewCondAt(mem []byte, l IPCLocker) (*Cond, error) {
+	if err := checkPlacement(mem, CondStateSize, 4); err != nil {
+		return nil, err
+	}
+	return (*Cond)(&cond{L: l, ftx: &futex{allocator.ByteSliceData(mem)}}), nil
+}
+
+func (c *cond) signal() error {
 	c.ftx.add(1)
 	_, err := c.ftx.wake(1)
-	if err != nil {
-		panic(err)
-	}
+	return err
 }
 
-func (c *cond) broadcast() {
+func (c *cond) broadcast() error {
 	c.ftx.add(1)
 	_, err := c.ftx.wakeAll()
-	if err != nil {
-		panic(err)
-	}
+	return err
 }
 
-func (c *cond) wait() {
-	seq := *c.ftx.addr()
-	c.L.Unlock()
-	if err := c.ftx.wait(seq, time.Duration(-1)); err != nil {
-		panic(err)
-	}
-	c.L.Lock()
+func (c *cond) wait() error {
+	_, err := c.waitTimeout(time.Duration(-1))
+	return err
 }
 
-func (c *cond) waitTimeout(timeout time.Duration) bool {
+func (c *cond) waitTimeout(timeout time.Duration) (bool, error) {
 	seq := *c.ftx.addr()
-	var success bool
-	c.L.Unlock()
-	if err := c.ftx.wait(seq, timeout); err == nil {
-		success = true
-	} else if !common.IsTimeoutErr(err) {
-		panic(err)
+	if err := unlockErr(c.L); err != nil {
+		return false, errors.Wrap(err, "failed to unlock the locker")
+	}
+	waitErr := c.ftx.wait(seq, timeout)
+	if err := lockErr(c.L); err != nil {
+		return false, errors.Wrap(err, "failed to lock the locker")
 	}
-	c.L.Lock()
-	return success
+	if waitErr == nil {
+		return true, nil
+	}
+	if common.IsTimeoutErr(waitErr) {
+		return false, nil
+	}
+	return false, waitErr
 }
 
 func (c *cond) close() error {
+	if c.region == nil {
+		return nil
+	}
 	if err := c.region.Close(); err != nil {
 		return errors.Wrap(err, "failed to close waiters list memory region")
 	}
@@ -90,6 +107,9 @@ func (c *cond) close() error {
 }
 
 func (c *cond) destroy() error {
+	if c.region == nil {
+		return nil
+	}
 	var result error
 	if err := c.close(); err != nil {
 		result = errors.Wrap(err, "destroy failed")
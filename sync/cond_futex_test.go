@@ -0,0 +1,41 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build freebsd linux,!waitlist_cond_linux
+
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCondAt(t *testing.T) {
+	a := assert.New(t)
+	region := createPlacementRegion(t, FutexMutexStateSize+CondStateSize)
+	defer destroyPlacementRegion(t, region)
+	m, err := NewFutexMutexAt(region.Data())
+	if !a.NoError(err) {
+		return
+	}
+	c, err := NewCondAt(region.Data()[FutexMutexStateSize:], m)
+	if !a.NoError(err) {
+		return
+	}
+	defer c.Close()
+	var ready bool
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		m.Lock()
+		ready = true
+		c.Signal()
+		m.Unlock()
+	}()
+	m.Lock()
+	for !ready {
+		c.Wait()
+	}
+	m.Unlock()
+	a.True(ready)
+}
@@ -21,10 +21,10 @@ const (
 
 type waiter uint32
 
-func newWaiter(ptr unsafe.Pointer) *waiter {
+func newWaiter(ptr unsafe.Pointer) (*waiter, error) {
 	w := (*waiter)(ptr)
 	*w = cSpinWaiterUnset
-	return w
+	return w, nil
 }
 
 func openWaiter(ptr unsafe.Pointer) *waiter {
@@ -32,11 +32,11 @@ func openWaiter(ptr unsafe.Pointer) *waiter {
 }
 
 // signal wakes a spin waiter.
-func (w *waiter) signal() (signaled bool) {
-	return atomic.CompareAndSwapUint32((*uint32)(w), cSpinWaiterUnset, cSpinWaiterSet)
+func (w *waiter) signal() (signaled bool, err error) {
+	return atomic.CompareAndSwapUint32((*uint32)(w), cSpinWaiterUnset, cSpinWaiterSet), nil
 }
 
-func (w *waiter) waitTimeout(timeout time.Duration) bool {
+func (w *waiter) waitTimeout(timeout time.Duration) (bool, error) {
 	var attempt uint64
 	start := time.Now()
 	ptr := (*uint32)(w)
@@ -49,13 +49,13 @@ func (w *waiter) waitTimeout(timeout time.Duration) bool {
 				if ret {
 					atomic.StoreUint32(ptr, cSpinWaiterWaitDone)
 				}
-				return ret
+				return ret, nil
 			}
 			runtime.Gosched()
 		}
 		attempt++
 	}
-	return true
+	return true, nil
 }
 
 func (w *waiter) isSame(ptr unsafe.Pointer) bool {
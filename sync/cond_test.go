@@ -67,6 +67,32 @@ func TestCondWaitTimeout(t *testing.T) {
 	a.False(cond.WaitTimeout(time.Millisecond * 50))
 }
 
+func TestCondErr(t *testing.T) {
+	a := assert.New(t)
+	cond, l, err := makeTestCond(a)
+	if err != nil {
+		return
+	}
+	defer destroyTestCond(a, cond, l)
+	l.Lock()
+	ok, err := cond.WaitTimeoutErr(time.Millisecond * 50)
+	a.NoError(err)
+	a.False(ok)
+	endCh := make(chan struct{})
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		a.NoError(cond.SignalErr())
+		endCh <- struct{}{}
+	}()
+	a.NoError(cond.WaitErr())
+	<-endCh
+	a.NoError(cond.BroadcastErr())
+	// the locker must be locked after successful waits.
+	fl := l.(FallibleLocker)
+	a.NoError(fl.UnlockErr())
+	a.Equal(ErrNotLocked, fl.UnlockErr())
+}
+
 func TestCondBroadcast(t *testing.T) {
 	a := assert.New(t)
 	cond, l, err := makeTestCond(a)
@@ -92,6 +118,34 @@ func TestCondBroadcast(t *testing.T) {
 	wg2.Wait()
 }
 
+func TestCondManyWaiters(t *testing.T) {
+	a := assert.New(t)
+	cond, l, err := makeTestCond(a)
+	if err != nil {
+		return
+	}
+	defer destroyTestCond(a, cond, l)
+	count := MaxCondWaiters * 2
+	var wg1, wg2 sync.WaitGroup
+	wg1.Add(count)
+	wg2.Add(count)
+	for i := 0; i < count; i++ {
+		go func() {
+			l.Lock()
+			wg1.Done()
+			a.NoError(cond.WaitErr())
+			l.Unlock()
+			wg2.Done()
+		}()
+	}
+	wg1.Wait()
+	// all the goroutines are waiting, when the locker is released.
+	l.Lock()
+	a.NoError(cond.BroadcastErr())
+	l.Unlock()
+	a.True(testutil.WaitForFunc(wg2.Wait, time.Second*10))
+}
+
 func TestCondMissedSignal(t *testing.T) {
 	a := assert.New(t)
 	cond, l, err := makeTestCond(a)
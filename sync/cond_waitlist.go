@@ -1,42 +1,59 @@
 // Copyright 2016 Aleksandr Demakin. All rights reserved.
 
-//+build windows darwin
+//+build windows darwin linux,waitlist_cond_linux
 
 package sync
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"os"
 	"time"
+	"unsafe"
 
 	"github.com/aybabtme/go-ipc/internal/allocator"
-	"github.com/aybabtme/go-ipc/internal/array"
 	"github.com/aybabtme/go-ipc/internal/helper"
 	"github.com/aybabtme/go-ipc/mmf"
 	"github.com/aybabtme/go-ipc/shm"
 	"github.com/pkg/errors"
 )
 
-// cond is a condvar implemented as a shared queue of waiters.
+// condState is the shared state of a waitlist-based cond.
+// Every waiter takes a ticket and creates an event, whose name is made of the cond id and the ticket.
+// The id is a random number, which is generated, when the cond is created.
+// Tickets in [signaled, next) belong to the waiters, which have not been signaled yet.
+// A signaler takes the first such ticket and sets its event. If the event does not exist,
+// the waiter has already gone, and the next ticket is taken.
+// The state is guarded by the list mutex, so the number of waiters is not limited.
+type condState struct {
+	id       uint64
+	next     uint64
+	signaled uint64
+}
+
+const condStateSize = int(unsafe.Sizeof(condState{}))
+
+// cond is a condvar implemented as a shared sequence of waiters.
+// It is used on windows and darwin, and can be selected on linux with 'waitlist_cond_linux' build tag.
 type cond struct {
-	L             IPCLocker
-	listLock      IPCLocker
-	name          string
-	waitersRegion *mmf.MemoryRegion
-	waiters       *array.SharedArray
+	L           IPCLocker
+	listLock    IPCLocker
+	name        string
+	stateRegion *mmf.MemoryRegion
+	state       *condState
 }
 
 func newCond(name string, flag int, perm os.FileMode, l IPCLocker) (*cond, error) {
-	size := array.CalcSharedArraySize(MaxCondWaiters, condWaiterSize)
 	if err := ensureOpenFlags(flag); err != nil {
 		return nil, err
 	}
 
-	region, created, err := helper.CreateWritableRegion(condSharedStateName(name), flag, perm, size)
+	region, created, err := helper.CreateWritableRegion(condSharedStateName(name), flag, perm, condStateSize)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create shared state")
 	}
 
-	result := &cond{L: l, name: name, waitersRegion: region}
+	result := &cond{L: l, name: name, stateRegion: region}
 
 	defer func() {
 		if err != nil {
@@ -57,75 +74,115 @@ func newCond(name string, flag int, perm os.FileMode, l IPCLocker) (*cond, error
 		return nil, errors.Wrap(err, "cond: failed to obtain internal lock")
 	}
 
-	rawData := allocator.ByteSliceData(result.waitersRegion.Data())
+	result.state = (*condState)(allocator.ByteSliceData(result.stateRegion.Data()))
 	if created {
-		result.waiters = array.NewSharedArray(rawData, MaxCondWaiters, condWaiterSize)
-	} else {
-		result.waiters = array.OpenSharedArray(rawData)
+		var id uint64
+		if id, err = newCondID(); err != nil {
+			return nil, errors.Wrap(err, "cond: failed to generate an id")
+		}
+		*result.state = condState{id: id}
 	}
 	return result, nil
 }
 
-func (c *cond) wait() {
-	c.doWait(time.Duration(-1))
+func (c *cond) wait() error {
+	_, err := c.doWait(time.Duration(-1))
+	return err
 }
 
-func (c *cond) waitTimeout(timeout time.Duration) bool {
+func (c *cond) waitTimeout(timeout time.Duration) (bool, error) {
 	return c.doWait(timeout)
 }
 
-func (c *cond) signal() {
-	c.listLock.Lock()
-	defer c.listLock.Unlock()
-	c.signalN(1)
+func (c *cond) signal() error {
+	return c.withListLock(func() error {
+		return c.signalN(1)
+	})
 }
 
-func (c *cond) broadcast() {
-	c.listLock.Lock()
-	defer c.listLock.Unlock()
-	c.signalN(c.waiters.Len())
+func (c *cond) broadcast() error {
+	return c.withListLock(func() error {
+		return c.signalN(-1)
+	})
 }
 
-// signalN wakes n waiters. Must be run with the list mutex locked.
-func (c *cond) signalN(count int) {
-	var signaled int
-	for i := 0; i < c.waiters.Len() && signaled < count; i++ {
-		if w := openWaiter(c.waiters.AtPointer(i)); w.signal() {
+// signalN wakes n waiters, or all of them, if n is negative. Must be run with the list mutex locked.
+func (c *cond) signalN(count int) error {
+	for signaled := 0; c.state.signaled < c.state.next && signaled != count; {
+		ticket := c.state.signaled
+		c.state.signaled++
+		ok, err := signalWaiter(c.state.id, ticket)
+		if err != nil {
+			return err
+		}
+		if ok {
 			signaled++
 		}
 	}
+	return nil
 }
 
-func (c *cond) doWait(timeout time.Duration) bool {
-	w := c.addToWaitersList()
+func (c *cond) doWait(timeout time.Duration) (bool, error) {
+	w, err := c.addWaiter()
+	if err != nil {
+		return false, err
+	}
 	// unlock resource locker
-	c.L.Unlock()
-	result := w.waitTimeout(timeout)
-	c.L.Lock()
-	c.cleanupWaiter(w)
-	return result
+	if err = unlockErr(c.L); err != nil {
+		c.cleanupWaiter(w)
+		return false, errors.Wrap(err, "failed to unlock the locker")
+	}
+	result, err := w.waitTimeout(timeout)
+	if e := lockErr(c.L); e != nil && err == nil {
+		err = errors.Wrap(e, "failed to lock the locker")
+	}
+	signaled, e := c.cleanupWaiter(w)
+	if e != nil && err == nil {
+		err = e
+	}
+	if err != nil {
+		return false, err
+	}
+	// if the waiter had been signaled after the timeout elapsed, the signal must not be lost.
+	return result || signaled, nil
 }
 
-func (c *cond) cleanupWaiter(w *waiter) {
-	c.listLock.Lock()
-	defer c.listLock.Unlock()
-	for i := 0; i < c.waiters.Len(); i++ {
-		if w.isSame(c.waiters.AtPointer(i)) {
-			w.destroy()
-			c.waiters.RemoveAt(i)
-			return
+// cleanupWaiter removes the waiter from the sequence and reports, whether it has been signaled.
+func (c *cond) cleanupWaiter(w *waiter) (bool, error) {
+	var signaled bool
+	err := c.withListLock(func() error {
+		w.destroy()
+		signaled = w.ticket < c.state.signaled
+		if !signaled && w.ticket+1 == c.state.next {
+			c.state.next--
 		}
-	}
+		return nil
+	})
+	return signaled, err
+}
+
+func (c *cond) addWaiter() (*waiter, error) {
+	var w *waiter
+	err := c.withListLock(func() error {
+		var err error
+		if w, err = newWaiter(c.state.id, c.state.next); err == nil {
+			c.state.next++
+		}
+		return err
+	})
+	return w, err
 }
 
-func (c *cond) addToWaitersList() *waiter {
-	c.listLock.Lock()
-	defer c.listLock.Unlock()
-	if c.waiters.Len() >= MaxCondWaiters {
-		panic(ErrTooManyWaiters)
+// withListLock calls f with the waiters list locked.
+func (c *cond) withListLock(f func() error) error {
+	if err := lockErr(c.listLock); err != nil {
+		return errors.Wrap(err, "failed to lock waiters list")
 	}
-	c.waiters.PushBack()
-	return newWaiter(c.waiters.AtPointer(c.waiters.Len() - 1))
+	err := f()
+	if e := unlockErr(c.listLock); e != nil && err == nil {
+		err = errors.Wrap(e, "failed to unlock waiters list")
+	}
+	return err
 }
 
 func (c *cond) close() error {
@@ -133,7 +190,7 @@ func (c *cond) close() error {
 	if err := c.listLock.Close(); err != nil {
 		result = errors.Wrap(err, "failed to close waiters list locker")
 	}
-	if err := c.waitersRegion.Close(); err != nil {
+	if err := c.stateRegion.Close(); err != nil {
 		result = errors.Wrap(err, "failed to close waiters list memory region")
 	}
 	return result
@@ -153,6 +210,20 @@ func (c *cond) destroy() error {
 	return result
 }
 
+// newCondID returns a random non-zero id, which distinguishes waiter events of different conds.
+// It is 64-bit wide, so that the events of different conds practically never have the same names.
+func newCondID() (uint64, error) {
+	var buff [8]byte
+	for {
+		if _, err := rand.Read(buff[:]); err != nil {
+			return 0, err
+		}
+		if id := binary.LittleEndian.Uint64(buff[:]); id != 0 {
+			return id, nil
+		}
+	}
+}
+
 func condMutexName(name string) string {
 	return name + ".m"
 }
@@ -162,8 +233,8 @@ func condSharedStateName(name string) string {
 }
 
 func condCleanup(result *cond, name string, created bool) {
-	if result.waitersRegion != nil {
-		result.waitersRegion.Close()
+	if result.stateRegion != nil {
+		result.stateRegion.Close()
 	}
 	if result.listLock != nil {
 		result.listLock.Close()
@@ -0,0 +1,91 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+//+build windows darwin linux,waitlist_cond_linux
+
+package sync
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var _ = registerTestBuildTag("waitlist_cond_linux")
+
+func TestCondTimedOutWaiterIsSkipped(t *testing.T) {
+	a := assert.New(t)
+	c, l, err := makeTestCond(a)
+	if err != nil {
+		return
+	}
+	defer destroyTestCond(a, c, l)
+	state := (*cond)(c).state
+	l.Lock()
+	// the last waiter returns its ticket on timeout.
+	a.False(c.WaitTimeout(time.Millisecond * 10))
+	a.Equal(uint64(0), state.next)
+	result := make(chan bool)
+	go func() {
+		l.Lock()
+		ok := c.WaitTimeout(time.Second * 5)
+		l.Unlock()
+		result <- ok
+	}()
+	// the second waiter takes its ticket, while the first one is waiting.
+	a.False(c.WaitTimeout(time.Millisecond * 200))
+	a.Equal(uint64(2), state.next)
+	a.Equal(uint64(0), state.signaled)
+	// the ticket of the first waiter, which has gone, must be skipped.
+	a.NoError(c.SignalErr())
+	l.Unlock()
+	select {
+	case ok := <-result:
+		a.True(ok)
+	case <-time.After(time.Second * 3):
+		t.Error("the waiter has not been signaled")
+	}
+	a.Equal(uint64(2), state.signaled)
+}
+
+func TestCondWaitersOfDifferentConds(t *testing.T) {
+	a := assert.New(t)
+	c, l, err := makeTestCond(a)
+	if err != nil {
+		return
+	}
+	defer destroyTestCond(a, c, l)
+	const otherName = testCondName + ".other"
+	a.NoError(DestroyCond(otherName))
+	other, err := NewCond(otherName, os.O_CREATE|os.O_EXCL, 0666, l)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		a.NoError(other.Destroy())
+	}()
+	id, otherID := (*cond)(c).state.id, (*cond)(other).state.id
+	a.NotZero(id)
+	a.NotEqual(id, otherID)
+	// a waiter of one cond must not replace a live waiter of another one with the same ticket.
+	w, err := newWaiter(id, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer w.destroy()
+	otherW, err := newWaiter(otherID, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer otherW.destroy()
+	ok, err := signalWaiter(id, 0)
+	a.NoError(err)
+	a.True(ok)
+	ok, err = w.waitTimeout(0)
+	a.NoError(err)
+	a.True(ok)
+	ok, err = otherW.waitTimeout(0)
+	a.NoError(err)
+	a.False(ok)
+}
@@ -26,18 +26,43 @@ func NewEvent(name string, flag int, perm os.FileMode, initial bool) (*Event, er
 	return (*Event)(e), nil
 }
 
-// Set sets the specified event object to the signaled state.
+// Set sets the specified event object to the signaled state. It panics on an error.
 func (e *Event) Set() {
-	(*event)(e).set()
+	if err := e.SetErr(); err != nil {
+		panic(err)
+	}
+}
+
+// SetErr sets the specified event object to the signaled state.
+// It returns an error, if the operation failed.
+func (e *Event) SetErr() error {
+	return (*event)(e).set()
 }
 
-// Wait waits for the event to be signaled.
+// Wait waits for the event to be signaled. It panics on an error.
 func (e *Event) Wait() {
-	(*event)(e).wait()
+	if err := e.WaitErr(); err != nil {
+		panic(err)
+	}
+}
+
+// WaitErr waits for the event to be signaled. It returns an error, if the operation failed.
+func (e *Event) WaitErr() error {
+	return (*event)(e).wait()
 }
 
-// WaitTimeout waits until the event is signaled or the timeout elapses.
+// WaitTimeout waits until the event is signaled or the timeout elapses. It panics on an error.
 func (e *Event) WaitTimeout(timeout time.Duration) bool {
+	result, err := e.WaitTimeoutErr(timeout)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// WaitTimeoutErr waits until the event is signaled or the timeout elapses.
+// It returns false and a nil error, if the timeout has elapsed.
+func (e *Event) WaitTimeoutErr(timeout time.Duration) (bool, error) {
 	return (*event)(e).waitTimeout(timeout)
 }
 
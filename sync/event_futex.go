@@ -15,6 +15,11 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	// EventStateSize is the size of the memory needed by NewEventAt.
+	EventStateSize = lweStateSize
+)
+
 type event struct {
 	name   string
 	region *mmf.MemoryRegion
@@ -42,23 +47,43 @@ func newEvent(name string, flag int, perm os.FileMode, initial bool) (*event, er
 	return result, nil
 }
 
-func (e *event) set() {
-	e.lwe.set()
+// NewEventAt creates an event, which state is stored in the given memory.
+// Zeroed memory is an event in non-signaled state.
+// The caller must keep the memory mapped, while the event is in use.
+// Close and Destroy are no-op for such events.
+//	mem - at least EventStateSize bytes, 4-byte aligned.
+func NewEventAt(mem []byte) (*Event, error) {
+	if err := checkPlacement(mem, EventStateSize, 4); err != nil {
+		return nil, err
+	}
+	state := allocator.ByteSliceData(mem)
+	return (*Event)(&event{lwe: newLightweightEvent(state, &futex{ptr: state})}), nil
+}
+
+func (e *event) set() error {
+	return e.lwe.set()
 }
 
-func (e *event) wait() {
-	e.waitTimeout(-1)
+func (e *event) wait() error {
+	_, err := e.waitTimeout(-1)
+	return err
 }
 
-func (e *event) waitTimeout(timeout time.Duration) bool {
+func (e *event) waitTimeout(timeout time.Duration) (bool, error) {
 	return e.lwe.waitTimeout(timeout)
 }
 
 func (e *event) close() error {
+	if e.region == nil {
+		return nil
+	}
 	return e.region.Close()
 }
 
 func (e *event) destroy() error {
+	if e.region == nil {
+		return nil
+	}
 	if err := e.close(); err != nil {
 		return errors.Wrap(err, "failed to close shm region")
 	}
@@ -51,15 +51,16 @@ func newEvent(name string, flag int, perm os.FileMode, initial bool) (*event, er
 	return result, nil
 }
 
-func (e *event) set() {
-	e.lwe.set()
+func (e *event) set() error {
+	return e.lwe.set()
 }
 
-func (e *event) wait() {
-	e.waitTimeout(-1)
+func (e *event) wait() error {
+	_, err := e.waitTimeout(-1)
+	return err
 }
 
-func (e *event) waitTimeout(timeout time.Duration) bool {
+func (e *event) waitTimeout(timeout time.Duration) (bool, error) {
 	return e.lwe.waitTimeout(timeout)
 }
 
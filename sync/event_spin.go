@@ -44,31 +44,33 @@ func newEvent(name string, flag int, perm os.FileMode, initial bool) (*event, er
 	return result, nil
 }
 
-func (e *event) set() {
+func (e *event) set() error {
 	atomic.StoreUint32(e.waiter, 1)
+	return nil
 }
 
-func (e *event) wait() {
+func (e *event) wait() error {
 	for i := uint64(0); !atomic.CompareAndSwapUint32(e.waiter, 1, 0); i++ {
 		if i%1000 == 0 {
 			runtime.Gosched()
 		}
 	}
+	return nil
 }
 
-func (e *event) waitTimeout(timeout time.Duration) bool {
+func (e *event) waitTimeout(timeout time.Duration) (bool, error) {
 	var attempt uint64
 	start := time.Now()
 	for !atomic.CompareAndSwapUint32(e.waiter, 1, 0) {
 		if attempt%1000 == 0 { // do not call time.Since too often.
 			if timeout >= 0 && time.Since(start) >= timeout {
-				return false
+				return false, nil
 			}
 			runtime.Gosched()
 		}
 		attempt++
 	}
-	return true
+	return true, nil
 }
 
 func (e *event) close() error {
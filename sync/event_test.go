@@ -242,6 +242,29 @@ func TestEventWait6(t *testing.T) {
 	a.True(ev.WaitTimeout(0))
 }
 
+func TestEventErr(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroyEvent(testEventName)) {
+		return
+	}
+	ev, err := NewEvent(testEventName, os.O_CREATE|os.O_EXCL, 0666, false)
+	if !a.NoError(err) || !a.NotNil(ev) {
+		return
+	}
+	defer func() {
+		a.NoError(ev.Destroy())
+	}()
+	ok, err := ev.WaitTimeoutErr(time.Millisecond * 50)
+	a.NoError(err)
+	a.False(ok)
+	a.NoError(ev.SetErr())
+	a.NoError(ev.WaitErr())
+	a.NoError(ev.SetErr())
+	ok, err = ev.WaitTimeoutErr(0)
+	a.NoError(err)
+	a.True(ok)
+}
+
 func TestEventSetAnotherProcess(t *testing.T) {
 	a := assert.New(t)
 	if !a.NoError(DestroyEvent(testEventName)) {
@@ -35,20 +35,45 @@ func NewWindowsEvent(name string, flag int, perm os.FileMode, initial bool) (*Wi
 	return &WindowsEvent{handle: handle}, nil
 }
 
-// Set sets the specified event object to the signaled state.
+// Set sets the specified event object to the signaled state. It panics on an error.
 func (e *WindowsEvent) Set() {
+	if err := e.SetErr(); err != nil {
+		panic(err)
+	}
+}
+
+// SetErr sets the specified event object to the signaled state.
+// It returns an error, if the operation failed.
+func (e *WindowsEvent) SetErr() error {
 	if err := windows.SetEvent(e.handle); err != nil {
-		panic("failed to set an event: " + err.Error())
+		return errors.Wrap(err, "failed to set an event")
 	}
+	return nil
 }
 
-// Wait waits for the event to be signaled.
+// Wait waits for the event to be signaled. It panics on an error.
 func (e *WindowsEvent) Wait() {
 	e.WaitTimeout(-1)
 }
 
-// WaitTimeout waits until the event is signaled or the timeout elapses.
+// WaitErr waits for the event to be signaled. It returns an error, if the operation failed.
+func (e *WindowsEvent) WaitErr() error {
+	_, err := e.WaitTimeoutErr(-1)
+	return err
+}
+
+// WaitTimeout waits until the event is signaled or the timeout elapses. It panics on an error.
 func (e *WindowsEvent) WaitTimeout(timeout time.Duration) bool {
+	result, err := e.WaitTimeoutErr(timeout)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// WaitTimeoutErr waits until the event is signaled or the timeout elapses.
+// It returns false and a nil error, if the timeout has elapsed.
+func (e *WindowsEvent) WaitTimeoutErr(timeout time.Duration) (bool, error) {
 	waitMillis := uint32(windows.INFINITE)
 	if timeout >= 0 {
 		waitMillis = uint32(timeout.Nanoseconds() / 1e6)
@@ -56,15 +81,14 @@ func (e *WindowsEvent) WaitTimeout(timeout time.Duration) bool {
 	ev, err := windows.WaitForSingleObject(e.handle, waitMillis)
 	switch ev {
 	case windows.WAIT_OBJECT_0:
-		return true
+		return true, nil
 	case uint32(windows.WAIT_TIMEOUT):
-		return false
+		return false, nil
 	default:
 		if err != nil {
-			panic(err)
-		} else {
-			panic(errors.Errorf("invalid wait state for an event: %d", ev))
+			return false, err
 		}
+		return false, errors.Errorf("invalid wait state for an event: %d", ev)
 	}
 }
 
@@ -46,3 +46,52 @@ func (w *futex) wake(count int32) (int, error) {
 func (w *futex) wakeAll() (int, error) {
 	return w.wake(cFutexWakeAll)
 }
+
+// futexSema is a semaphore, which counter is a futex value.
+// Being a waitWaker, it decrements the counter on wait, and increments it on wake.
+type futexSema struct {
+	futex
+}
+
+func newFutexSema(ptr unsafe.Pointer) *futexSema {
+	return &futexSema{futex{ptr: ptr}}
+}
+
+func (s *futexSema) tryWait() bool {
+	for {
+		value := atomic.LoadInt32(s.addr())
+		if value <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(s.addr(), value, value-1) {
+			return true
+		}
+	}
+}
+
+func (s *futexSema) wait(unused int32, timeout time.Duration) error {
+	var err error
+	var acquired bool
+	common.CallTimeout(func(curTimeout time.Duration) bool {
+		if acquired = s.tryWait(); acquired {
+			return false
+		}
+		err = s.futex.wait(0, curTimeout)
+		return err == nil
+	}, timeout)
+	if acquired {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.tryWait() {
+		return nil
+	}
+	return common.NewTimeoutError("FUTEX")
+}
+
+func (s *futexSema) wake(count int32) (int, error) {
+	s.add(int(count))
+	return s.futex.wake(count)
+}
@@ -0,0 +1,16 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+func createPlatformLocker(typ, name string, flag int) (sync.Locker, error) {
+	return nil, fmt.Errorf("unknown object type %q", typ)
+}
+
+func destroyPlatformLocker(typ, name string) error {
+	return fmt.Errorf("unknown object type %q", typ)
+}
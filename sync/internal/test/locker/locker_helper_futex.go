@@ -0,0 +1,31 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux freebsd
+
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	ipc_sync "github.com/aybabtme/go-ipc/sync"
+)
+
+func createPlatformLocker(typ, name string, flag int) (locker sync.Locker, err error) {
+	switch typ {
+	case "ticket":
+		locker, err = ipc_sync.NewTicketMutex(name, flag, 0666)
+	default:
+		err = fmt.Errorf("unknown object type %q", typ)
+	}
+	return
+}
+
+func destroyPlatformLocker(typ, name string) error {
+	switch typ {
+	case "ticket":
+		return ipc_sync.DestroyTicketMutex(name)
+	default:
+		return fmt.Errorf("unknown object type %q", typ)
+	}
+}
@@ -5,7 +5,6 @@
 package main
 
 import (
-	"fmt"
 	"sync"
 
 	ipc_sync "github.com/aybabtme/go-ipc/sync"
@@ -22,7 +21,7 @@ func createLocker(typ, name string, flag int) (locker sync.Locker, err error) {
 	case "rw":
 		locker, err = ipc_sync.NewRWMutex(name, flag, 0666)
 	default:
-		err = fmt.Errorf("unknown object type %q", typ)
+		locker, err = createPlatformLocker(typ, name, flag)
 	}
 	return
 }
@@ -38,6 +37,6 @@ func destroyLocker(typ, name string) error {
 	case "rw":
 		return ipc_sync.DestroyRWMutex(name)
 	default:
-		return fmt.Errorf("unknown object type %q", typ)
+		return destroyPlatformLocker(typ, name)
 	}
 }
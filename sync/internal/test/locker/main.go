@@ -8,6 +8,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/aybabtme/go-ipc/internal/allocator"
@@ -22,6 +23,7 @@ var (
 	objType  = flag.String("type", "m", "synchronization object type - m | spin")
 	jobs     = flag.Int("jobs", 1, "count of simultaneous jobs")
 	readlock = flag.Bool("ro", false, "use read lock where possible")
+	undo     = flag.Bool("undo", false, "use SEM_UNDO flag, if the object supports it")
 )
 
 type readLocker interface {
@@ -36,6 +38,8 @@ available commands:
     increments an int64 value at the beginning of the shm_name region n times
   test shm_name n {expected values byte array}
     performs n reads from shm_name and compares the results with the expected data
+  lock
+    locks the object and hangs, so that it can be killed while holding the lock
 if jobs > 1, all goroutines will execute operations reads.
 byte array should be passed as a continuous string of 2-symbol hex byte values like '01020A'
 `
@@ -57,6 +61,23 @@ func destroy() error {
 	return destroyLocker(*objType, *objName)
 }
 
+func lock() error {
+	if flag.NArg() != 1 {
+		return fmt.Errorf("lock: must not provide any arguments")
+	}
+	var lockerFlag int
+	if *undo {
+		lockerFlag = ipc_sync.SEM_UNDO
+	}
+	locker, err := createLocker(*objType, *objName, lockerFlag)
+	if err != nil {
+		return err
+	}
+	locker.Lock()
+	time.Sleep(time.Hour)
+	return nil
+}
+
 func inc64() error {
 	if flag.NArg() != 3 {
 		return fmt.Errorf("test: must provide exactly two arguments")
@@ -187,6 +208,8 @@ func runCommand() error {
 		return create()
 	case "destroy":
 		return destroy()
+	case "lock":
+		return lock()
 	case "inc64":
 		return inc64()
 	case "test":
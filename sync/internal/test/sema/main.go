@@ -15,12 +15,15 @@ import (
 var (
 	timeout = flag.Int("timeout", -1, "timeout for wait, in ms.")
 	fail    = flag.Bool("fail", false, "operation must fail")
+	undo    = flag.Bool("undo", false, "use SEM_UNDO flag")
 )
 
 const usage = `  test program for semaphores.
 available commands:
   wait sema_name
   signal sema_name count
+  acquire sema_name count
+    acquires count units and hangs, so that it can be killed while holding them
 `
 
 func wait() error {
@@ -61,6 +64,27 @@ func signal() error {
 	return s.Close()
 }
 
+func acquire() error {
+	if flag.NArg() != 3 {
+		return fmt.Errorf("acquire: must provide sema name and count")
+	}
+	var semFlag int
+	if *undo {
+		semFlag = sync.SEM_UNDO
+	}
+	s, err := sync.NewSemaphore(flag.Arg(1), semFlag, 0666, 0)
+	if err != nil {
+		return err
+	}
+	count, err := strconv.Atoi(flag.Arg(2))
+	if err != nil {
+		return err
+	}
+	s.WaitN(count, -1)
+	time.Sleep(time.Hour)
+	return s.Close()
+}
+
 func runCommand() error {
 	command := flag.Arg(0)
 	switch command {
@@ -68,6 +92,8 @@ func runCommand() error {
 		return wait()
 	case "signal":
 		return signal()
+	case "acquire":
+		return acquire()
 	default:
 		return fmt.Errorf("unknown command")
 	}
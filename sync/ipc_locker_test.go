@@ -409,6 +409,33 @@ func testLockerTwiceUnlock(t *testing.T, ctor lockerCtor, dtor lockerDtor) {
 	})
 }
 
+func testLockerErr(t *testing.T, ctor lockerCtor, dtor lockerDtor) {
+	a := assert.New(t)
+	if !a.NoError(dtor(testLockerName)) {
+		return
+	}
+	m, err := ctor(testLockerName, os.O_CREATE|os.O_EXCL, 0666)
+	if !a.NoError(err) || !a.NotNil(m) {
+		return
+	}
+	defer func() {
+		a.NoError(m.Close())
+	}()
+	defer dtor(testLockerName)
+	fl, ok := m.(FallibleLocker)
+	if !a.True(ok) {
+		return
+	}
+	a.NoError(fl.LockErr())
+	if tfl, ok := m.(TimedFallibleLocker); ok {
+		locked, err := tfl.LockTimeoutErr(time.Millisecond * 50)
+		a.NoError(err)
+		a.False(locked)
+	}
+	a.NoError(fl.UnlockErr())
+	a.Equal(ErrNotLocked, fl.UnlockErr())
+}
+
 func benchmarkLocker(b *testing.B, ctor lockerCtor, dtor lockerDtor) {
 	a := assert.New(b)
 	if !a.NoError(dtor(testLockerName)) {
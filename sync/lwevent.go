@@ -38,12 +38,12 @@ func (e *lwEvent) init(set bool) {
 	*e.state = val
 }
 
-func (e *lwEvent) set() {
+func (e *lwEvent) set() error {
 	var old int32
 	for {
 		old = atomic.LoadInt32(e.state)
 		if old < 0 {
-			return
+			return nil
 		}
 		new := old | math.MinInt32
 		if atomic.CompareAndSwapInt32(e.state, old, new) {
@@ -51,8 +51,11 @@ func (e *lwEvent) set() {
 		}
 	}
 	if old > 0 {
-		e.ww.wake(1)
+		if _, err := e.ww.wake(1); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func (e *lwEvent) obtainOrChange(inc int32) (new int32, obtained bool) {
@@ -76,25 +79,27 @@ func (e *lwEvent) obtainOrChange(inc int32) (new int32, obtained bool) {
 	}
 }
 
-func (e *lwEvent) waitTimeout(timeout time.Duration) bool {
+func (e *lwEvent) waitTimeout(timeout time.Duration) (bool, error) {
 	// first, we are trying to catch the event, or add us as a waiter.
 	new, obtained := e.obtainOrChange(1)
 	if obtained {
-		return true
+		return true, nil
 	}
 	// in the loop we wait for the value to change and then observe new value:
 	//	if it is still not set, wait again
 	//	otherwise, try to obtain the event.
 	for {
 		if err := e.ww.wait(new, timeout); err != nil {
-			if common.IsTimeoutErr(err) {
-				_, obtained = e.obtainOrChange(-1)
-				return obtained
+			// on an error we remove us from the waiters, unless the event has been set.
+			_, obtained = e.obtainOrChange(-1)
+			if obtained || common.IsTimeoutErr(err) {
+				return obtained, nil
 			}
+			return false, err
 		}
 		new, obtained = e.obtainOrChange(0)
 		if obtained {
-			return true
+			return true, nil
 		}
 	}
 }
@@ -8,15 +8,16 @@ import (
 	"unsafe"
 
 	"github.com/aybabtme/go-ipc/internal/common"
+	"github.com/aybabtme/go-ipc/internal/lw"
 )
 
 const (
-	lwmStateSize = 4
+	lwmStateSize = lw.MutexStateSize
 
 	lwmSpinCount         = 100
-	lwmUnlocked          = int32(0)
-	lwmLockedNoWaiters   = int32(1)
-	lwmLockedHaveWaiters = int32(2)
+	lwmUnlocked          = int32(lw.MutexUnlocked)
+	lwmLockedNoWaiters   = int32(lw.MutexLockedNoWaiters)
+	lwmLockedHaveWaiters = int32(lw.MutexLockedHaveWaiters)
 )
 
 // lwMutex is a lightweight mutex implementation operating on a uint32 memory cell.
@@ -37,24 +38,36 @@ func (lwm *lwMutex) init() {
 }
 
 func (lwm *lwMutex) lock() {
-	if err := lwm.doLock(-1); err != nil {
+	if err := lwm.lockErr(); err != nil {
 		panic(err)
 	}
 }
 
+func (lwm *lwMutex) lockErr() error {
+	return lwm.doLock(-1)
+}
+
 func (lwm *lwMutex) tryLock() bool {
 	return atomic.CompareAndSwapInt32(lwm.state, lwmUnlocked, lwmLockedNoWaiters)
 }
 
 func (lwm *lwMutex) lockTimeout(timeout time.Duration) bool {
+	result, err := lwm.lockTimeoutErr(timeout)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func (lwm *lwMutex) lockTimeoutErr(timeout time.Duration) (bool, error) {
 	err := lwm.doLock(timeout)
 	if err == nil {
-		return true
+		return true, nil
 	}
 	if common.IsTimeoutErr(err) {
-		return false
+		return false, nil
 	}
-	panic(err)
+	return false, err
 }
 
 func (lwm *lwMutex) doLock(timeout time.Duration) error {
@@ -67,32 +80,50 @@ func (lwm *lwMutex) doLock(timeout time.Duration) error {
 	if old != lwmLockedHaveWaiters {
 		old = atomic.SwapInt32(lwm.state, lwmLockedHaveWaiters)
 	}
+	var deadline time.Time
+	if timeout >= 0 {
+		deadline = time.Now().Add(timeout)
+	}
 	for old != lwmUnlocked {
 		if err := lwm.ww.wait(lwmLockedHaveWaiters, timeout); err != nil {
 			return err
 		}
 		old = atomic.SwapInt32(lwm.state, lwmLockedHaveWaiters)
+		// some waiters, like spinWW, may return before the timeout elapses,
+		// so the remaining time is checked here.
+		if old != lwmUnlocked && timeout >= 0 {
+			if timeout = time.Until(deadline); timeout <= 0 {
+				return common.NewTimeoutError("LOCK")
+			}
+		}
 	}
 	return nil
 }
 
 func (lwm *lwMutex) unlock() {
+	if err := lwm.unlockErr(); err != nil {
+		panic(err)
+	}
+}
+
+func (lwm *lwMutex) unlockErr() error {
 	if old := atomic.LoadInt32(lwm.state); old == lwmLockedHaveWaiters {
 		*lwm.state = lwmUnlocked
 	} else {
 		if old == lwmUnlocked {
-			panic("unlock of unlocked mutex")
+			return ErrNotLocked
 		}
 		if atomic.SwapInt32(lwm.state, lwmUnlocked) == lwmLockedNoWaiters {
-			return
+			return nil
 		}
 	}
 	for i := 0; i < lwmSpinCount; i++ {
 		if *lwm.state != lwmUnlocked {
 			if atomic.CompareAndSwapInt32(lwm.state, lwmLockedNoWaiters, lwmLockedHaveWaiters) {
-				return
+				return nil
 			}
 		}
 	}
-	lwm.ww.wake(1)
+	_, err := lwm.ww.wake(1)
+	return err
 }
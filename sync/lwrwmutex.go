@@ -4,45 +4,140 @@ package sync
 
 import (
 	"sync/atomic"
+	"time"
 	"unsafe"
+
+	"github.com/aybabtme/go-ipc/internal/allocator"
+	"github.com/aybabtme/go-ipc/internal/common"
+	"github.com/aybabtme/go-ipc/internal/lw"
 )
 
 const (
-	lwRWMStateSize          = 8
-	lwRWMMask               = 0x1FFFFF
-	lwRWMWaitingReaderShift = 21
-	lwRWMWriterShift        = 42
+	lwRWMStateSize          = lw.RWMutexStateSize
+	lwRWMMask               = lw.RWMutexMask
+	lwRWMWaitingReaderShift = lw.RWMutexWaitingReaderShift
+	lwRWMWaitingWriterShift = lw.RWMutexWaitingWriterShift
+	lwRWMWriterBit          = lw.RWMutexWriterBit
+	lwRWMPolicyShift        = lw.RWMutexPolicyShift
+	lwRWMPolicyMask         = lw.RWMutexPolicyMask
+	lwRWMUpgradingBit       = lw.RWMutexUpgradingBit
 )
 
-// wRWState is a shared rwmutex state with the following bits distribution:
-//  ...63...|62.................42|41.................21|20.................0|
-//  --------|---------------------|---------------------|--------------------|
-//   unused |       writers       |   waiting readers   |      readers       |
-// which gives us up to 2kk readers and writers.
-type lwRWState int64
+// lwRWState is a shared rwmutex state with the following bits distribution:
+//  ....63...|62..61|..60..|59..............40|39..............20|19...............0|
+//  ---------|------|------|------------------|------------------|------------------|
+//  upgrading|policy|writer| waiting writers  | waiting readers  |     readers      |
+// which gives us up to 1kk readers and writers.
+// The upgrading bit is set along with the writer bit by the upgradable reader, which waits for other readers to leave.
+// Waiters are granted the lock by the same state change, which makes it possible, so, when a waiter
+// is woken, the lock is already held on its behalf. Waiters of the same kind are not distinguished,
+// so a waiter, which gives up waiting, removes any waiter of its kind, if there is one.
+// Otherwise, the lock has been granted to it, and it must take the wakeup, which is on its way.
+type lwRWState uint64
 
 func (s lwRWState) readers() int64 {
-	return (int64)(s) & lwRWMMask
+	return int64(s) & lwRWMMask
 }
 
 func (s lwRWState) waitingReaders() int64 {
-	return ((int64)(s) >> lwRWMWaitingReaderShift) & lwRWMMask
+	return (int64(s) >> lwRWMWaitingReaderShift) & lwRWMMask
+}
+
+func (s lwRWState) waitingWriters() int64 {
+	return (int64(s) >> lwRWMWaitingWriterShift) & lwRWMMask
 }
 
-func (s lwRWState) writers() int64 {
-	return ((int64)(s) >> lwRWMWriterShift) & lwRWMMask
+func (s lwRWState) writer() bool {
+	return s&lwRWMWriterBit != 0
+}
+
+func (s lwRWState) upgrading() bool {
+	return s&lwRWMUpgradingBit != 0
+}
+
+func (s lwRWState) policy() RWPolicy {
+	return RWPolicy((s >> lwRWMPolicyShift) & lwRWMPolicyMask)
 }
 
 func (s *lwRWState) addReaders(count int64) {
-	*(*int64)(s) += count
+	*s += lwRWState(count)
 }
 
 func (s *lwRWState) addWaitingReaders(count int64) {
-	*(*int64)(s) += count << lwRWMWaitingReaderShift
+	*s += lwRWState(count << lwRWMWaitingReaderShift)
+}
+
+func (s *lwRWState) addWaitingWriters(count int64) {
+	*s += lwRWState(count << lwRWMWaitingWriterShift)
+}
+
+func (s *lwRWState) setWriter(locked bool) {
+	if locked {
+		*s |= lwRWMWriterBit
+	} else {
+		*s &^= lwRWMWriterBit
+	}
+}
+
+func (s *lwRWState) setUpgrading(upgrading bool) {
+	if upgrading {
+		*s |= lwRWMUpgradingBit
+	} else {
+		*s &^= lwRWMUpgradingBit
+	}
+}
+
+// canRead returns true, if a new reader can take the lock.
+func (s lwRWState) canRead() bool {
+	return !s.writer() && (s.waitingWriters() == 0 || s.policy() == RWPolicyPreferReaders)
 }
 
-func (s *lwRWState) addWriters(count int64) {
-	*(*int64)(s) += count << lwRWMWriterShift
+// canWrite returns true, if a new writer can take the lock.
+func (s lwRWState) canWrite() bool {
+	return !s.writer() && s.readers() == 0
+}
+
+// lwRWGrant describes the waiters, which have been granted the lock.
+type lwRWGrant struct {
+	writer   bool
+	readers  int64
+	upgrader bool
+}
+
+// grant passes the lock to waiters, if it is possible, according to the policy.
+// writerReleased tells, that the state change has released the write lock.
+// If both a writer and readers can be granted the lock, balanced policy lets the readers in
+// only after a writer, so that readers, which keep arriving, do not starve the writers.
+func (s *lwRWState) grant(writerReleased bool) lwRWGrant {
+	if s.writer() {
+		if s.upgrading() && s.readers() == 0 {
+			s.setUpgrading(false)
+			return lwRWGrant{upgrader: true}
+		}
+		return lwRWGrant{}
+	}
+	wr := s.waitingReaders()
+	canGrantWriter := s.readers() == 0 && s.waitingWriters() > 0
+	canGrantReaders := wr > 0 && (s.readers() == 0 || s.canRead())
+	if canGrantWriter && canGrantReaders {
+		switch s.policy() {
+		case RWPolicyPreferWriters:
+			canGrantReaders = false
+		case RWPolicyBalanced:
+			canGrantReaders = writerReleased
+		}
+	}
+	if canGrantReaders {
+		s.addWaitingReaders(-wr)
+		s.addReaders(wr)
+		return lwRWGrant{readers: wr}
+	}
+	if canGrantWriter {
+		s.addWaitingWriters(-1)
+		s.setWriter(true)
+		return lwRWGrant{writer: true}
+	}
+	return lwRWGrant{}
 }
 
 // lwRWMutex is an optimized low-level rwmutex implementation,
@@ -50,81 +145,269 @@ func (s *lwRWState) addWriters(count int64) {
 // this implementation is inspired by Jeff Preshing and his article at
 // http://preshing.com/20150316/semaphores-are-surprisingly-versatile/
 // and his c++ implementation (github.com/preshing/cpp11-on-multicore).
+// Upgradable readers are serialized by a separate mutex, so only one of them
+// can wait for other readers to leave, and it is woken by its own waiter.
 type lwRWMutex struct {
-	rWaiter waitWaker
-	wWaiter waitWaker
-	state   *int64
+	rWaiter   waitWaker
+	wWaiter   waitWaker
+	uWaiter   waitWaker
+	upgraders *lwMutex
+	state     *uint64
 }
 
-func newRWLightweightMutex(state unsafe.Pointer, rWaiter, wWaiter waitWaker) *lwRWMutex {
-	return &lwRWMutex{state: (*int64)(state), rWaiter: rWaiter, wWaiter: wWaiter}
+func newRWLightweightMutex(state unsafe.Pointer, w *rwWaiters) *lwRWMutex {
+	return &lwRWMutex{
+		state:     (*uint64)(state),
+		rWaiter:   w.r,
+		wWaiter:   w.w,
+		uWaiter:   w.u,
+		upgraders: newLightweightMutex(allocator.AdvancePointer(state, lwRWMStateSize), w.l),
+	}
 }
 
 // init writes initial value into mutex's memory location.
-func (lwrw *lwRWMutex) init() {
-	*lwrw.state = 0
+func (lwrw *lwRWMutex) init(policy RWPolicy) {
+	*lwrw.state = uint64(policy) << lwRWMPolicyShift
+	lwrw.upgraders.init()
+}
+
+func (lwrw *lwRWMutex) policy() RWPolicy {
+	return lwRWState(atomic.LoadUint64(lwrw.state)).policy()
 }
 
 func (lwrw *lwRWMutex) lock() {
-	new := (lwRWState)(atomic.AddInt64(lwrw.state, 1<<lwRWMWriterShift))
-	if new.readers() > 0 || new.writers() > 1 {
-		if err := lwrw.wWaiter.wait(0, -1); err != nil {
-			panic(err)
+	if err := lwrw.lockErr(); err != nil {
+		panic(err)
+	}
+}
+
+func (lwrw *lwRWMutex) lockErr() error {
+	_, err := lwrw.doLock(-1)
+	return err
+}
+
+func (lwrw *lwRWMutex) tryLock() bool {
+	locked, _ := lwrw.modify(func(s *lwRWState) bool {
+		if !s.canWrite() {
+			return false
+		}
+		s.setWriter(true)
+		return true
+	})
+	return locked
+}
+
+func (lwrw *lwRWMutex) lockTimeoutErr(timeout time.Duration) (bool, error) {
+	return lwrw.doLock(timeout)
+}
+
+func (lwrw *lwRWMutex) doLock(timeout time.Duration) (bool, error) {
+	var locked bool
+	lwrw.modify(func(s *lwRWState) bool {
+		if locked = s.canWrite(); locked {
+			s.setWriter(true)
+		} else {
+			s.addWaitingWriters(1)
 		}
+		return true
+	})
+	if locked {
+		return true, nil
 	}
+	return lwrw.waitGrant(lwrw.wWaiter, timeout, func(s *lwRWState) bool {
+		if s.waitingWriters() == 0 {
+			return false
+		}
+		s.addWaitingWriters(-1)
+		return true
+	}, lwrw.unlockErr)
 }
 
 func (lwrw *lwRWMutex) rlock() {
-	var new lwRWState
-	for {
-		old := (lwRWState)(atomic.LoadInt64(lwrw.state))
-		new = old
-		if new.writers() == 0 {
-			new.addReaders(1)
+	if err := lwrw.rlockErr(); err != nil {
+		panic(err)
+	}
+}
+
+func (lwrw *lwRWMutex) rlockErr() error {
+	_, err := lwrw.doRLock(-1)
+	return err
+}
+
+func (lwrw *lwRWMutex) tryRLock() bool {
+	locked, _ := lwrw.modify(func(s *lwRWState) bool {
+		if !s.canRead() {
+			return false
+		}
+		s.addReaders(1)
+		return true
+	})
+	return locked
+}
+
+func (lwrw *lwRWMutex) rlockTimeoutErr(timeout time.Duration) (bool, error) {
+	return lwrw.doRLock(timeout)
+}
+
+func (lwrw *lwRWMutex) doRLock(timeout time.Duration) (bool, error) {
+	var locked bool
+	lwrw.modify(func(s *lwRWState) bool {
+		if locked = s.canRead(); locked {
+			s.addReaders(1)
 		} else {
-			new.addWaitingReaders(1)
+			s.addWaitingReaders(1)
 		}
-		if atomic.CompareAndSwapInt64(lwrw.state, (int64)(old), (int64)(new)) {
-			break
+		return true
+	})
+	if locked {
+		return true, nil
+	}
+	return lwrw.waitGrant(lwrw.rWaiter, timeout, func(s *lwRWState) bool {
+		if s.waitingReaders() == 0 {
+			return false
 		}
+		s.addWaitingReaders(-1)
+		return true
+	}, lwrw.runlockErr)
+}
+
+// waitGrant waits until the lock is granted to a waiter.
+// If the timeout elapses, or the wait fails, cancel is applied to the state to remove the waiter.
+// If it is not possible, the lock has already been granted, and the waiter takes the wakeup.
+// In case of an error, the granted lock is released with release.
+func (lwrw *lwRWMutex) waitGrant(ww waitWaker, timeout time.Duration, cancel func(s *lwRWState) bool, release func() error) (bool, error) {
+	err := ww.wait(0, timeout)
+	if err == nil {
+		return true, nil
+	}
+	if cancelled, e := lwrw.modify(cancel); cancelled {
+		if common.IsTimeoutErr(err) {
+			return false, e
+		}
+		return false, err
+	}
+	if e := ww.wait(0, -1); e != nil {
+		return false, e
+	}
+	if common.IsTimeoutErr(err) {
+		return true, nil
+	}
+	release()
+	return false, err
+}
+
+func (lwrw *lwRWMutex) upgradableLockErr() error {
+	if err := lwrw.upgraders.lockErr(); err != nil {
+		return err
+	}
+	if err := lwrw.rlockErr(); err != nil {
+		lwrw.upgraders.unlockErr()
+		return err
 	}
-	if new.writers() > 0 {
-		if err := lwrw.rWaiter.wait(0, -1); err != nil {
-			panic(err)
+	return nil
+}
+
+func (lwrw *lwRWMutex) upgradableUnlockErr() error {
+	err := lwrw.runlockErr()
+	if e := lwrw.upgraders.unlockErr(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// upgradeErr turns the upgradable read lock into the write lock.
+// It stops new readers and writers, and waits for other readers to leave.
+func (lwrw *lwRWMutex) upgradeErr() error {
+	var locked bool
+	upgraded, err := lwrw.modify(func(s *lwRWState) bool {
+		if s.readers() == 0 || s.writer() {
+			return false
+		}
+		s.addReaders(-1)
+		s.setWriter(true)
+		if locked = s.readers() == 0; !locked {
+			s.setUpgrading(true)
 		}
+		return true
+	})
+	if !upgraded {
+		return ErrNotLocked
 	}
+	if err == nil && !locked {
+		err = lwrw.uWaiter.wait(0, -1)
+	}
+	if err != nil {
+		return err
+	}
+	// the writer excludes other upgradable readers, so they can now wait for the read lock.
+	return lwrw.upgraders.unlockErr()
 }
 
 func (lwrw *lwRWMutex) runlock() {
-	new := (lwRWState)(atomic.AddInt64(lwrw.state, -1))
-	if new.readers() == lwRWMMask {
-		panic("unlock of unlocked mutex")
+	if err := lwrw.runlockErr(); err != nil {
+		panic(err)
 	}
-	if new.readers() == 0 && new.writers() > 0 {
-		lwrw.wWaiter.wake(1)
+}
+
+func (lwrw *lwRWMutex) runlockErr() error {
+	unlocked, err := lwrw.modify(func(s *lwRWState) bool {
+		if s.readers() == 0 {
+			return false
+		}
+		s.addReaders(-1)
+		return true
+	})
+	if !unlocked {
+		return ErrNotLocked
 	}
+	return err
 }
 
 func (lwrw *lwRWMutex) unlock() {
-	var new lwRWState
-	for {
-		old := (lwRWState)(atomic.LoadInt64(lwrw.state))
-		if old.writers() == 0 {
-			panic("unlock of unlocked mutex")
+	if err := lwrw.unlockErr(); err != nil {
+		panic(err)
+	}
+}
+
+func (lwrw *lwRWMutex) unlockErr() error {
+	unlocked, err := lwrw.modify(func(s *lwRWState) bool {
+		if !s.writer() {
+			return false
 		}
-		new = old
-		new.addWriters(-1)
-		if wr := new.waitingReaders(); wr > 0 {
-			new.addWaitingReaders(-wr)
-			new.addReaders(wr)
+		s.setWriter(false)
+		return true
+	})
+	if !unlocked {
+		return ErrNotLocked
+	}
+	return err
+}
+
+// modify atomically applies f to the state and passes the lock to the waiters, if it becomes possible.
+// f returns false, if the state must not be changed. In this case modify returns false.
+func (lwrw *lwRWMutex) modify(f func(s *lwRWState) bool) (bool, error) {
+	for {
+		old := lwRWState(atomic.LoadUint64(lwrw.state))
+		new := old
+		if !f(&new) {
+			return false, nil
 		}
-		if atomic.CompareAndSwapInt64(lwrw.state, (int64)(old), (int64)(new)) {
-			break
+		granted := new.grant(old.writer() && !new.writer())
+		if atomic.CompareAndSwapUint64(lwrw.state, uint64(old), uint64(new)) {
+			return true, lwrw.wake(granted)
 		}
 	}
-	if new.readers() > 0 {
-		lwrw.rWaiter.wake(int32(new.readers()))
-	} else if new.writers() > 0 {
-		lwrw.wWaiter.wake(1)
+}
+
+func (lwrw *lwRWMutex) wake(granted lwRWGrant) error {
+	var err error
+	switch {
+	case granted.writer:
+		_, err = lwrw.wWaiter.wake(1)
+	case granted.readers > 0:
+		_, err = lwrw.rWaiter.wake(int32(granted.readers))
+	case granted.upgrader:
+		_, err = lwrw.uWaiter.wake(1)
 	}
+	return err
 }
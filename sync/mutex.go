@@ -3,6 +3,7 @@
 package sync
 
 import (
+	"errors"
 	"io"
 	"os"
 	"sync"
@@ -22,6 +23,30 @@ type TimedIPCLocker interface {
 	LockTimeout(timeout time.Duration) bool
 }
 
+// FallibleLocker is a locker, which returns errors instead of panicking.
+// All mutexes in this package implement it.
+// If an operation fails, the state of the locker is undefined,
+// as it usually means, that its shared state became inaccessible.
+type FallibleLocker interface {
+	// LockErr locks the locker.
+	LockErr() error
+	// UnlockErr unlocks the locker. It returns ErrNotLocked, if the locker was not locked.
+	UnlockErr() error
+}
+
+// TimedFallibleLocker is a fallible locker, whose lock operation can be limited with duration.
+type TimedFallibleLocker interface {
+	FallibleLocker
+	// LockTimeoutErr tries to lock the locker, waiting for not more, than timeout.
+	// It returns false and a nil error, if the timeout has elapsed.
+	LockTimeoutErr(timeout time.Duration) (bool, error)
+}
+
+var (
+	// ErrNotLocked is returned by UnlockErr methods, if the locker was not locked.
+	ErrNotLocked = errors.New("unlock of unlocked mutex")
+)
+
 // NewMutex creates a new interprocess mutex.
 // It uses the default implementation on the current platform.
 //	name - object name.
@@ -39,3 +64,21 @@ func DestroyMutex(name string) error {
 func mutexSharedStateName(name, typ string) string {
 	return name + ".s" + typ
 }
+
+// lockErr locks l. It calls LockErr, if l is a FallibleLocker.
+func lockErr(l IPCLocker) error {
+	if fl, ok := l.(FallibleLocker); ok {
+		return fl.LockErr()
+	}
+	l.Lock()
+	return nil
+}
+
+// unlockErr unlocks l. It calls UnlockErr, if l is a FallibleLocker.
+func unlockErr(l IPCLocker) error {
+	if fl, ok := l.(FallibleLocker); ok {
+		return fl.UnlockErr()
+	}
+	l.Unlock()
+	return nil
+}
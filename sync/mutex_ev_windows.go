@@ -18,8 +18,9 @@ import (
 
 // all implementations must satisfy IPCLocker interface.
 var (
-	_          IPCLocker = (*EventMutex)(nil)
-	timeoutErr           = common.NewTimeoutError("WaitForSingleObject")
+	_          IPCLocker           = (*EventMutex)(nil)
+	_          TimedFallibleLocker = (*EventMutex)(nil)
+	timeoutErr                     = common.NewTimeoutError("WaitForSingleObject")
 )
 
 // EventMutex is a mutex built on named windows events.
@@ -85,6 +86,22 @@ func (m *EventMutex) Unlock() {
 	m.lwm.unlock()
 }
 
+// LockErr locks the mutex. It returns an error, if the operation failed.
+func (m *EventMutex) LockErr() error {
+	return m.lwm.lockErr()
+}
+
+// LockTimeoutErr tries to lock the mutex, waiting for not more, than timeout.
+// It returns false and a nil error, if the timeout has elapsed.
+func (m *EventMutex) LockTimeoutErr(timeout time.Duration) (bool, error) {
+	return m.lwm.lockTimeoutErr(timeout)
+}
+
+// UnlockErr releases the mutex. It returns ErrNotLocked, if the mutex is not locked.
+func (m *EventMutex) UnlockErr() error {
+	return m.lwm.unlockErr()
+}
+
 // Close closes event's handle.
 func (m *EventMutex) Close() error {
 	m.state.Close()
@@ -103,7 +120,7 @@ type eventWaiter struct {
 
 func (e *eventWaiter) wake(int32) (int, error) {
 	if err := windows.SetEvent(e.handle); err != nil {
-		panic("failed to unlock mutex: " + err.Error())
+		return 0, errors.Wrap(err, "failed to unlock mutex")
 	}
 	return 1, nil
 }
@@ -121,9 +138,8 @@ func (e *eventWaiter) wait(unused int32, timeout time.Duration) error {
 		return timeoutErr
 	default:
 		if err != nil {
-			panic(err)
-		} else {
-			panic(errors.Errorf("invalid wait state for a mutex: %d", ev))
+			return err
 		}
+		return errors.Errorf("invalid wait state for a mutex: %d", ev)
 	}
 }
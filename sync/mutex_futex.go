@@ -16,9 +16,15 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	// FutexMutexStateSize is the size of the memory needed by NewFutexMutexAt.
+	FutexMutexStateSize = lwmStateSize
+)
+
 // all implementations must satisfy at least IPCLocker interface.
 var (
-	_ TimedIPCLocker = (*FutexMutex)(nil)
+	_ TimedIPCLocker      = (*FutexMutex)(nil)
+	_ TimedFallibleLocker = (*FutexMutex)(nil)
 )
 
 // FutexMutex is a mutex based on linux/freebsd futex object.
@@ -55,6 +61,20 @@ func NewFutexMutex(name string, flag int, perm os.FileMode) (*FutexMutex, error)
 	return result, nil
 }
 
+// NewFutexMutexAt creates a futex-based mutex, which state is stored in the given memory.
+// It allows to place many mutexes into a single shared memory region along with the data they protect.
+// Zeroed memory is an unlocked mutex, so the memory of a newly created shm object needs no initialization.
+// The caller must keep the memory mapped, while the mutex is in use.
+// Close and Destroy are no-op for such mutexes.
+//	mem - at least FutexMutexStateSize bytes, 4-byte aligned.
+func NewFutexMutexAt(mem []byte) (*FutexMutex, error) {
+	if err := checkPlacement(mem, FutexMutexStateSize, 4); err != nil {
+		return nil, err
+	}
+	data := allocator.ByteSliceData(mem)
+	return &FutexMutex{lwm: newLightweightMutex(data, &futex{ptr: data})}, nil
+}
+
 // Lock locks the mutex. It panics on an error.
 func (f *FutexMutex) Lock() {
 	f.lwm.lock()
@@ -75,14 +95,36 @@ func (f *FutexMutex) Unlock() {
 	f.lwm.unlock()
 }
 
+// LockErr locks the mutex. It returns an error, if the operation failed.
+func (f *FutexMutex) LockErr() error {
+	return f.lwm.lockErr()
+}
+
+// LockTimeoutErr tries to lock the mutex, waiting for not more, than timeout.
+// It returns false and a nil error, if the timeout has elapsed.
+func (f *FutexMutex) LockTimeoutErr(timeout time.Duration) (bool, error) {
+	return f.lwm.lockTimeoutErr(timeout)
+}
+
+// UnlockErr releases the mutex. It returns ErrNotLocked, if the mutex is not locked.
+func (f *FutexMutex) UnlockErr() error {
+	return f.lwm.unlockErr()
+}
+
 // Close indicates, that the object is no longer in use,
 // and that the underlying resources can be freed.
 func (f *FutexMutex) Close() error {
+	if f.region == nil {
+		return nil
+	}
 	return f.region.Close()
 }
 
 // Destroy removes the mutex object.
 func (f *FutexMutex) Destroy() error {
+	if f.region == nil {
+		return nil
+	}
 	if err := f.Close(); err != nil {
 		return errors.Wrap(err, "failed to close shm region")
 	}
@@ -19,6 +19,14 @@ func BenchmarkFutexMutex(b *testing.B) {
 	})
 }
 
+func BenchmarkTicketMutex(b *testing.B) {
+	benchmarkLocker(b, func(name string, mode int, perm os.FileMode) (IPCLocker, error) {
+		return NewTicketMutex(name, mode, perm)
+	}, func(name string) error {
+		return DestroyTicketMutex(name)
+	})
+}
+
 func BenchmarkFutexMutexAsRW(b *testing.B) {
 	a := assert.New(b)
 	DestroyFutexMutex(testLockerName)
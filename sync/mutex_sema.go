@@ -4,10 +4,12 @@ package sync
 
 import (
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/aybabtme/go-ipc/internal/allocator"
 	"github.com/aybabtme/go-ipc/internal/helper"
+	"github.com/aybabtme/go-ipc/internal/lw"
 	"github.com/aybabtme/go-ipc/mmf"
 	"github.com/aybabtme/go-ipc/shm"
 
@@ -16,30 +18,59 @@ import (
 
 // all implementations must satisfy IPCLocker interface.
 var (
-	_ IPCLocker = (*SemaMutex)(nil)
+	_ IPCLocker           = (*SemaMutex)(nil)
+	_ TimedFallibleLocker = (*SemaMutex)(nil)
 )
 
-// SemaMutex is a semaphore-based mutex for unix.
+// semaLocker is a mutex implementation used by SemaMutex.
+type semaLocker interface {
+	lock()
+	lockErr() error
+	tryLock() bool
+	lockTimeout(timeout time.Duration) bool
+	lockTimeoutErr(timeout time.Duration) (bool, error)
+	unlock()
+	unlockErr() error
+}
+
+// SemaMutex is a semaphore-based mutex for unix. It always uses System V semaphores.
 type SemaMutex struct {
 	s      *Semaphore
 	region *mmf.MemoryRegion
 	name   string
-	lwm    *lwMutex
+	lwm    semaLocker
 }
 
 // NewSemaMutex creates a new semaphore-based mutex.
 //	name - object name.
-//	flag - flag is a combination of open flags from 'os' package.
+//	flag - flag is a combination of open flags from 'os' package along with SEM_UNDO flag.
 //	perm - object's permission bits.
+// If SEM_UNDO is set, the mutex is the semaphore itself, acquired with SEM_UNDO flag,
+// so the system unlocks the mutex, if its owner process dies.
+// Such a mutex must be unlocked by the process, which locked it,
+// and all processes must open it with the same SEM_UNDO setting, otherwise NewSemaMutex fails.
+// The shared state has grown from 4 to 8 bytes with the mode, so mutexes,
+// which were created by previous versions, must be destroyed before they are opened by this one.
 func NewSemaMutex(name string, flag int, perm os.FileMode) (*SemaMutex, error) {
-	if err := ensureOpenFlags(flag); err != nil {
+	if err := ensureOpenFlags(flag &^ SEM_UNDO); err != nil {
 		return nil, err
 	}
-	region, created, err := helper.CreateWritableRegion(mutexSharedStateName(name, "s"), flag, perm, lwmStateSize)
+	region, created, err := helper.CreateWritableRegion(mutexSharedStateName(name, "s"), flag&^SEM_UNDO, perm, lw.SemaMutexStateSize)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create shared state")
 	}
-	s, err := NewSemaphore(name, flag, perm, 1)
+	mode := uint32(lw.SemaMutexModeLw)
+	if flag&SEM_UNDO != 0 {
+		mode = lw.SemaMutexModeUndo
+	}
+	if err = checkSemaMutexMode(region, mode); err != nil {
+		region.Close()
+		if created {
+			shm.DestroyMemoryObject(mutexSharedStateName(name, "s"))
+		}
+		return nil, err
+	}
+	s, err := newSemaMutexSemaphore(name, flag, perm)
 	if err != nil {
 		region.Close()
 		if created {
@@ -51,14 +82,42 @@ func NewSemaMutex(name string, flag int, perm os.FileMode) (*SemaMutex, error) {
 		s:      s,
 		region: region,
 		name:   name,
-		lwm:    newLightweightMutex(allocator.ByteSliceData(region.Data()), newSemaWaiter(s)),
 	}
+	if flag&SEM_UNDO != 0 {
+		result.lwm, err = newSemaUndoMutex(s)
+		if err != nil {
+			result.Close()
+			if created {
+				DestroySemaMutex(name)
+			}
+			return nil, err
+		}
+		return result, nil
+	}
+	lwm := newLightweightMutex(allocator.ByteSliceData(region.Data()), newSemaWaiter(s))
 	if created {
-		result.lwm.init()
+		lwm.init()
 	}
+	result.lwm = lwm
 	return result, nil
 }
 
+// checkSemaMutexMode stores the mode in the shared state, if it has not been set yet,
+// and returns an error, if the mutex has been opened in another mode.
+func checkSemaMutexMode(region *mmf.MemoryRegion, mode uint32) error {
+	ptr := (*uint32)(allocator.AdvancePointer(allocator.ByteSliceData(region.Data()), lw.SemaMutexModeOffset))
+	if atomic.CompareAndSwapUint32(ptr, 0, mode) {
+		return nil
+	}
+	if atomic.LoadUint32(ptr) != mode {
+		if mode == lw.SemaMutexModeUndo {
+			return errors.New("the mutex has been opened without SEM_UNDO")
+		}
+		return errors.New("the mutex has been opened with SEM_UNDO")
+	}
+	return nil
+}
+
 // Lock locks the mutex. It panics on an error.
 func (m *SemaMutex) Lock() {
 	m.lwm.lock()
@@ -79,6 +138,22 @@ func (m *SemaMutex) Unlock() {
 	m.lwm.unlock()
 }
 
+// LockErr locks the mutex. It returns an error, if the operation failed.
+func (m *SemaMutex) LockErr() error {
+	return m.lwm.lockErr()
+}
+
+// LockTimeoutErr tries to lock the mutex, waiting for not more, than timeout.
+// It returns false and a nil error, if the timeout has elapsed.
+func (m *SemaMutex) LockTimeoutErr(timeout time.Duration) (bool, error) {
+	return m.lwm.lockTimeoutErr(timeout)
+}
+
+// UnlockErr releases the mutex. It returns ErrNotLocked, if the mutex is not locked.
+func (m *SemaMutex) UnlockErr() error {
+	return m.lwm.unlockErr()
+}
+
 // Close closes shared state of the mutex.
 func (m *SemaMutex) Close() error {
 	e1, e2 := m.s.Close(), m.region.Close()
@@ -104,7 +179,7 @@ func DestroySemaMutex(name string) error {
 	if err := shm.DestroyMemoryObject(mutexSharedStateName(name, "s")); err != nil {
 		return errors.Wrap(err, "failed to destroy shared state")
 	}
-	if err := DestroySemaphore(name); err != nil && !os.IsNotExist(errors.Cause(err)) {
+	if err := destroySemaMutexSemaphore(name); err != nil && !os.IsNotExist(errors.Cause(err)) {
 		return err
 	}
 	return nil
@@ -15,9 +15,15 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	// SpinMutexStateSize is the size of the memory needed by NewSpinMutexAt.
+	SpinMutexStateSize = lwmStateSize
+)
+
 // all implementations must satisfy IPCLocker interface.
 var (
-	_ IPCLocker = (*SpinMutex)(nil)
+	_ IPCLocker           = (*SpinMutex)(nil)
+	_ TimedFallibleLocker = (*SpinMutex)(nil)
 )
 
 // SpinMutex is a synchronization object which performs busy wait loop.
@@ -62,6 +68,18 @@ func NewSpinMutex(name string, flag int, perm os.FileMode) (*SpinMutex, error) {
 	return result, nil
 }
 
+// NewSpinMutexAt creates a spin mutex, which state is stored in the given memory.
+// Zeroed memory is an unlocked mutex.
+// The caller must keep the memory mapped, while the mutex is in use.
+// Close and Destroy are no-op for such mutexes.
+//	mem - at least SpinMutexStateSize bytes, 4-byte aligned.
+func NewSpinMutexAt(mem []byte) (*SpinMutex, error) {
+	if err := checkPlacement(mem, SpinMutexStateSize, 4); err != nil {
+		return nil, err
+	}
+	return &SpinMutex{lwm: newLightweightMutex(allocator.ByteSliceData(mem), new(spinWW))}, nil
+}
+
 // Lock locks the mutex waiting in a busy loop if needed.
 func (spin *SpinMutex) Lock() {
 	spin.lwm.lock()
@@ -77,6 +95,22 @@ func (spin *SpinMutex) Unlock() {
 	spin.lwm.unlock()
 }
 
+// LockErr locks the mutex. It returns an error, if the operation failed.
+func (spin *SpinMutex) LockErr() error {
+	return spin.lwm.lockErr()
+}
+
+// LockTimeoutErr tries to lock the mutex, waiting for not more, than timeout.
+// It returns false and a nil error, if the timeout has elapsed.
+func (spin *SpinMutex) LockTimeoutErr(timeout time.Duration) (bool, error) {
+	return spin.lwm.lockTimeoutErr(timeout)
+}
+
+// UnlockErr releases the mutex. It returns ErrNotLocked, if the mutex is not locked.
+func (spin *SpinMutex) UnlockErr() error {
+	return spin.lwm.unlockErr()
+}
+
 // TryLock makes one attempt to lock the mutex. It return true on succeess and false otherwise.
 func (spin *SpinMutex) TryLock() bool {
 	return spin.lwm.tryLock()
@@ -85,11 +119,17 @@ func (spin *SpinMutex) TryLock() bool {
 // Close indicates, that the object is no longer in use,
 // and that the underlying resources can be freed.
 func (spin *SpinMutex) Close() error {
+	if spin.region == nil {
+		return nil
+	}
 	return spin.region.Close()
 }
 
 // Destroy removes the mutex object.
 func (spin *SpinMutex) Destroy() error {
+	if spin.region == nil {
+		return nil
+	}
 	if err := spin.Close(); err != nil {
 		return errors.Wrap(err, "failed to close spin mutex")
 	}
@@ -5,6 +5,9 @@ package sync
 import (
 	"os"
 	"testing"
+	"unsafe"
+
+	"github.com/aybabtme/go-ipc/internal/allocator"
 )
 
 func spinCtor(name string, mode int, perm os.FileMode) (IPCLocker, error) {
@@ -50,3 +53,15 @@ func TestSpinMutexValueInc(t *testing.T) {
 func TestSpinMutexPanicsOnDoubleUnlock(t *testing.T) {
 	testLockerTwiceUnlock(t, spinCtor, spinDtor)
 }
+
+func TestSpinMutexErr(t *testing.T) {
+	testLockerErr(t, spinCtor, spinDtor)
+}
+
+func TestSpinMutexAt(t *testing.T) {
+	mem := make([]int32, 1)
+	testLockerLock(t, func(string, int, os.FileMode) (IPCLocker, error) {
+		return NewSpinMutexAt(allocator.ByteSliceFromUnsafePointer(unsafe.Pointer(&mem[0]), SpinMutexStateSize, SpinMutexStateSize))
+	}, nil)
+	allocator.Use(unsafe.Pointer(&mem[0]))
+}
@@ -7,6 +7,11 @@ package sync
 import (
 	"os"
 	"testing"
+	"time"
+
+	testutil "github.com/aybabtme/go-ipc/internal/test"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func sysvMutexCtor(name string, flag int, perm os.FileMode) (IPCLocker, error) {
@@ -60,3 +65,78 @@ func TestSysvMutexLockTimeout2(t *testing.T) {
 func TestSysvMutexPanicsOnDoubleUnlock(t *testing.T) {
 	testLockerTwiceUnlock(t, sysvMutexCtor, sysvMutexDtor)
 }
+
+func TestSysvMutexErr(t *testing.T) {
+	testLockerErr(t, sysvMutexCtor, sysvMutexDtor)
+}
+
+func sysvUndoMutexCtor(name string, flag int, perm os.FileMode) (IPCLocker, error) {
+	return NewSemaMutex(name, flag|SEM_UNDO, perm)
+}
+
+func TestSysvUndoMutexLock(t *testing.T) {
+	testLockerLock(t, sysvUndoMutexCtor, sysvMutexDtor)
+}
+
+func TestSysvUndoMutexLockTimeout(t *testing.T) {
+	testLockerLockTimeout(t, "msysv", sysvUndoMutexCtor, sysvMutexDtor)
+}
+
+func TestSysvUndoMutexPanicsOnDoubleUnlock(t *testing.T) {
+	testLockerTwiceUnlock(t, sysvUndoMutexCtor, sysvMutexDtor)
+}
+
+func TestSysvUndoMutexErr(t *testing.T) {
+	testLockerErr(t, sysvUndoMutexCtor, sysvMutexDtor)
+}
+
+func TestSysvUndoMutexModeMismatch(t *testing.T) {
+	a := assert.New(t)
+	for _, createFlag := range []int{0, SEM_UNDO} {
+		if !a.NoError(DestroySemaMutex(testLockerName)) {
+			return
+		}
+		m, err := NewSemaMutex(testLockerName, os.O_CREATE|os.O_EXCL|createFlag, 0666)
+		if !a.NoError(err) {
+			return
+		}
+		_, err = NewSemaMutex(testLockerName, createFlag^SEM_UNDO, 0666)
+		a.Error(err)
+		m2, err := NewSemaMutex(testLockerName, createFlag, 0666)
+		if a.NoError(err) {
+			a.NoError(m2.Close())
+		}
+		a.NoError(m.Destroy())
+	}
+}
+
+func TestSysvUndoMutexKilledOwner(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroySemaMutex(testLockerName)) {
+		return
+	}
+	m, err := NewSemaMutex(testLockerName, os.O_CREATE|os.O_EXCL|SEM_UNDO, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	defer m.Destroy()
+	killCh := make(chan bool, 1)
+	resultCh := testutil.RunTestAppAsync(argsForSyncLockCommand(testLockerName, "msysv", true), killCh)
+	deadline := time.Now().Add(time.Second * 10)
+	for m.TryLock() {
+		m.Unlock()
+		if time.Now().After(deadline) {
+			killCh <- true
+			t.Errorf("the mutex was not locked by another process")
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	killCh <- true
+	if _, ok := testutil.WaitForAppResultChan(resultCh, time.Second*5); !a.True(ok) {
+		return
+	}
+	if a.True(m.LockTimeout(time.Second)) {
+		m.Unlock()
+	}
+}
@@ -58,3 +58,7 @@ func TestMutexLockTimeout2(t *testing.T) {
 func TestMutexPanicsOnDoubleUnlock(t *testing.T) {
 	testLockerTwiceUnlock(t, mutexCtor, mutexDtor)
 }
+
+func TestMutexErr(t *testing.T) {
+	testLockerErr(t, mutexCtor, mutexDtor)
+}
@@ -0,0 +1,228 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux freebsd
+
+package sync
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/aybabtme/go-ipc/internal/allocator"
+	"github.com/aybabtme/go-ipc/internal/common"
+	"github.com/aybabtme/go-ipc/internal/helper"
+	"github.com/aybabtme/go-ipc/mmf"
+	"github.com/aybabtme/go-ipc/shm"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// TicketMutexStateSize is the size of the memory needed by NewTicketMutexAt.
+	TicketMutexStateSize = int(unsafe.Sizeof(ticketState{}))
+
+	tmCancelSlots         = 128
+	tmCancelRetryInterval = time.Millisecond
+)
+
+// all implementations must satisfy at least IPCLocker interface.
+var (
+	_ TimedIPCLocker      = (*TicketMutex)(nil)
+	_ TimedFallibleLocker = (*TicketMutex)(nil)
+)
+
+// ticketState is the shared state of a ticket mutex.
+// A locker takes the next ticket and waits until it is served.
+// A locker, which gives up waiting, puts its ticket into one of the cancel slots,
+// so that the unlocker skips it. Cancelled tickets are stored as ticket+1, zero means an empty slot.
+type ticketState struct {
+	serving   uint32
+	next      uint32
+	cancelled [tmCancelSlots]uint64
+}
+
+// TicketMutex is a fair mutex based on linux/freebsd futex object.
+// Unlike FutexMutex, which lets a newly arriving locker take the lock before sleeping waiters,
+// it grants the lock strictly in the order of arrival. The price is lower throughput under contention,
+// as the lock can not be reacquired by the current owner, while others are waiting for it.
+type TicketMutex struct {
+	state  *ticketState
+	ftx    *futex
+	region *mmf.MemoryRegion
+	name   string
+}
+
+// NewTicketMutex creates a new fair futex-based mutex.
+//	name - object name.
+//	flag - flag is a combination of open flags from 'os' package.
+//	perm - object's permission bits.
+func NewTicketMutex(name string, flag int, perm os.FileMode) (*TicketMutex, error) {
+	if err := ensureOpenFlags(flag); err != nil {
+		return nil, err
+	}
+	region, created, err := helper.CreateWritableRegion(mutexSharedStateName(name, "tk"), flag, perm, TicketMutexStateSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create shared state")
+	}
+	result := newTicketMutex(allocator.ByteSliceData(region.Data()))
+	result.region, result.name = region, name
+	if created {
+		*result.state = ticketState{}
+	}
+	return result, nil
+}
+
+// NewTicketMutexAt creates a ticket mutex, which state is stored in the given memory.
+// Zeroed memory is an unlocked mutex.
+// The caller must keep the memory mapped, while the mutex is in use.
+// Close and Destroy are no-op for such mutexes.
+//	mem - at least TicketMutexStateSize bytes, 8-byte aligned.
+func NewTicketMutexAt(mem []byte) (*TicketMutex, error) {
+	if err := checkPlacement(mem, TicketMutexStateSize, 8); err != nil {
+		return nil, err
+	}
+	return newTicketMutex(allocator.ByteSliceData(mem)), nil
+}
+
+func newTicketMutex(data unsafe.Pointer) *TicketMutex {
+	state := (*ticketState)(data)
+	return &TicketMutex{state: state, ftx: &futex{ptr: unsafe.Pointer(&state.serving)}}
+}
+
+// Lock locks the mutex. It panics on an error.
+func (m *TicketMutex) Lock() {
+	if err := m.LockErr(); err != nil {
+		panic(err)
+	}
+}
+
+// TryLock makes one attempt to lock the mutex. It return true on succeess and false otherwise.
+// It fails, if the mutex is locked, or if there are waiters.
+func (m *TicketMutex) TryLock() bool {
+	serving := atomic.LoadUint32(&m.state.serving)
+	return serving == atomic.LoadUint32(&m.state.next) &&
+		atomic.CompareAndSwapUint32(&m.state.next, serving, serving+1)
+}
+
+// LockTimeout tries to lock the locker, waiting for not more, than timeout. It panics on an error.
+func (m *TicketMutex) LockTimeout(timeout time.Duration) bool {
+	result, err := m.LockTimeoutErr(timeout)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// Unlock releases the mutex. It panics on an error, or if the mutex is not locked.
+func (m *TicketMutex) Unlock() {
+	if err := m.UnlockErr(); err != nil {
+		panic(err)
+	}
+}
+
+// LockErr locks the mutex. It returns an error, if the operation failed.
+func (m *TicketMutex) LockErr() error {
+	_, err := m.doLock(-1)
+	return err
+}
+
+// LockTimeoutErr tries to lock the mutex, waiting for not more, than timeout.
+// It returns false and a nil error, if the timeout has elapsed.
+// If there are more, than 128 waiters, which have given up waiting, but have not been skipped yet,
+// it may wait for a bit longer, than timeout.
+func (m *TicketMutex) LockTimeoutErr(timeout time.Duration) (bool, error) {
+	return m.doLock(timeout)
+}
+
+// UnlockErr releases the mutex and passes it to the next waiter.
+// It returns ErrNotLocked, if the mutex is not locked.
+func (m *TicketMutex) UnlockErr() error {
+	if atomic.LoadUint32(&m.state.serving) == atomic.LoadUint32(&m.state.next) {
+		return ErrNotLocked
+	}
+	serving := atomic.AddUint32(&m.state.serving, 1)
+	for atomic.CompareAndSwapUint64(m.cancelSlot(serving), uint64(serving)+1, 0) {
+		serving = atomic.AddUint32(&m.state.serving, 1)
+	}
+	if serving == atomic.LoadUint32(&m.state.next) {
+		return nil
+	}
+	// all waiters are woken, as each of them waits for its own ticket.
+	_, err := m.ftx.wakeAll()
+	return err
+}
+
+func (m *TicketMutex) doLock(timeout time.Duration) (bool, error) {
+	ticket := atomic.AddUint32(&m.state.next, 1) - 1
+	var deadline time.Time
+	if timeout >= 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		serving := atomic.LoadUint32(&m.state.serving)
+		if serving == ticket {
+			return true, nil
+		}
+		if timeout >= 0 {
+			if timeout = time.Until(deadline); timeout <= 0 {
+				return m.cancel(ticket), nil
+			}
+		}
+		if err := m.ftx.wait(int32(serving), timeout); err != nil && !common.IsTimeoutErr(err) {
+			// the ticket must leave the queue, otherwise the mutex will never be passed to the next waiters.
+			if m.cancel(ticket) {
+				m.UnlockErr()
+			}
+			return false, err
+		}
+	}
+}
+
+// cancel removes the ticket from the queue. It returns true, if the mutex has been passed to the ticket meanwhile.
+func (m *TicketMutex) cancel(ticket uint32) bool {
+	slot := m.cancelSlot(ticket)
+	for !atomic.CompareAndSwapUint64(slot, 0, uint64(ticket)+1) {
+		// the slot is used by another cancelled ticket, which has not been skipped yet.
+		serving := atomic.LoadUint32(&m.state.serving)
+		if serving == ticket {
+			return true
+		}
+		m.ftx.wait(int32(serving), tmCancelRetryInterval)
+	}
+	// if the unlocker has already reached the ticket, either it skips it, or we take the mutex.
+	return atomic.LoadUint32(&m.state.serving) == ticket && atomic.CompareAndSwapUint64(slot, uint64(ticket)+1, 0)
+}
+
+func (m *TicketMutex) cancelSlot(ticket uint32) *uint64 {
+	return &m.state.cancelled[ticket%tmCancelSlots]
+}
+
+// Close indicates, that the object is no longer in use,
+// and that the underlying resources can be freed.
+func (m *TicketMutex) Close() error {
+	if m.region == nil {
+		return nil
+	}
+	return m.region.Close()
+}
+
+// Destroy removes the mutex object.
+func (m *TicketMutex) Destroy() error {
+	if m.region == nil {
+		return nil
+	}
+	if err := m.Close(); err != nil {
+		return errors.Wrap(err, "failed to close shm region")
+	}
+	return DestroyTicketMutex(m.name)
+}
+
+// DestroyTicketMutex permanently removes mutex with the given name.
+func DestroyTicketMutex(name string) error {
+	if err := shm.DestroyMemoryObject(mutexSharedStateName(name, "tk")); err != nil {
+		return errors.Wrap(err, "failed to destroy memory object")
+	}
+	return nil
+}
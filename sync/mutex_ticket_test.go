@@ -0,0 +1,190 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux freebsd
+
+package sync
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/aybabtme/go-ipc/internal/allocator"
+	testutil "github.com/aybabtme/go-ipc/internal/test"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ticketCtor(name string, mode int, perm os.FileMode) (IPCLocker, error) {
+	return NewTicketMutex(name, mode, perm)
+}
+
+func ticketDtor(name string) error {
+	return DestroyTicketMutex(name)
+}
+
+func TestTicketMutexOpenMode(t *testing.T) {
+	testLockerOpenMode(t, ticketCtor, ticketDtor)
+}
+
+func TestTicketMutexOpenMode2(t *testing.T) {
+	testLockerOpenMode2(t, ticketCtor, ticketDtor)
+}
+
+func TestTicketMutexOpenMode3(t *testing.T) {
+	testLockerOpenMode3(t, ticketCtor, ticketDtor)
+}
+
+func TestTicketMutexOpenMode4(t *testing.T) {
+	testLockerOpenMode4(t, ticketCtor, ticketDtor)
+}
+
+func TestTicketMutexOpenMode5(t *testing.T) {
+	testLockerOpenMode5(t, ticketCtor, ticketDtor)
+}
+
+func TestTicketMutexLock(t *testing.T) {
+	testLockerLock(t, ticketCtor, ticketDtor)
+}
+
+func TestTicketMutexMemory(t *testing.T) {
+	testLockerMemory(t, "ticket", false, ticketCtor, ticketDtor)
+}
+
+func TestTicketMutexValueInc(t *testing.T) {
+	testLockerValueInc(t, "ticket", ticketCtor, ticketDtor)
+}
+
+func TestTicketMutexLockTimeout(t *testing.T) {
+	testLockerLockTimeout(t, "ticket", ticketCtor, ticketDtor)
+}
+
+func TestTicketMutexLockTimeout2(t *testing.T) {
+	testLockerLockTimeout2(t, "ticket", ticketCtor, ticketDtor)
+}
+
+func TestTicketMutexPanicsOnDoubleUnlock(t *testing.T) {
+	testLockerTwiceUnlock(t, ticketCtor, ticketDtor)
+}
+
+func TestTicketMutexErr(t *testing.T) {
+	testLockerErr(t, ticketCtor, ticketDtor)
+}
+
+func TestTicketMutexAt(t *testing.T) {
+	mem := make([]uint64, TicketMutexStateSize/8)
+	testLockerLock(t, func(string, int, os.FileMode) (IPCLocker, error) {
+		return NewTicketMutexAt(allocator.ByteSliceFromUnsafePointer(unsafe.Pointer(&mem[0]), TicketMutexStateSize, TicketMutexStateSize))
+	}, nil)
+	allocator.Use(unsafe.Pointer(&mem[0]))
+}
+
+func makeTestTicketMutex(t *testing.T) *TicketMutex {
+	a := assert.New(t)
+	a.NoError(DestroyTicketMutex(testLockerName))
+	m, err := NewTicketMutex(testLockerName, os.O_CREATE|os.O_EXCL, 0666)
+	if !a.NoError(err) {
+		t.FailNow()
+	}
+	return m
+}
+
+// waitForTickets waits until count tickets have been taken.
+func waitForTickets(m *TicketMutex, count uint32) bool {
+	return testutil.WaitForFunc(func() {
+		for atomic.LoadUint32(&m.state.next) != count {
+			time.Sleep(time.Millisecond)
+		}
+	}, time.Second*3)
+}
+
+func TestTicketMutexFIFO(t *testing.T) {
+	const waiters = 16
+	a := assert.New(t)
+	m := makeTestTicketMutex(t)
+	defer m.Destroy()
+	var order []int
+	var wg sync.WaitGroup
+	m.Lock()
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m.Lock()
+			order = append(order, i)
+			m.Unlock()
+		}(i)
+		if !a.True(waitForTickets(m, uint32(i+2))) {
+			return
+		}
+	}
+	a.False(m.TryLock())
+	m.Unlock()
+	wg.Wait()
+	for i := range order {
+		a.Equal(i, order[i])
+	}
+	a.True(m.TryLock())
+	m.Unlock()
+}
+
+func TestTicketMutexCancelledWaiterIsSkipped(t *testing.T) {
+	a := assert.New(t)
+	m := makeTestTicketMutex(t)
+	defer m.Destroy()
+	m.Lock()
+	timedOut := make(chan bool)
+	go func() {
+		timedOut <- m.LockTimeout(time.Millisecond * 50)
+	}()
+	if !a.True(waitForTickets(m, 2)) {
+		return
+	}
+	locked := make(chan struct{})
+	go func() {
+		m.Lock()
+		close(locked)
+	}()
+	if !a.True(waitForTickets(m, 3)) {
+		return
+	}
+	a.False(<-timedOut)
+	m.Unlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second * 3):
+		t.Error("the mutex has not been passed to the next waiter")
+	}
+	a.Equal(uint32(2), atomic.LoadUint32(&m.state.serving))
+	m.Unlock()
+}
+
+func TestTicketMutexManyCancelledWaiters(t *testing.T) {
+	const waiters = tmCancelSlots * 2
+	a := assert.New(t)
+	m := makeTestTicketMutex(t)
+	defer m.Destroy()
+	var wg sync.WaitGroup
+	m.Lock()
+	wg.Add(waiters)
+	for i := 0; i < waiters; i++ {
+		go func() {
+			defer wg.Done()
+			// waiters, which do not find a free cancel slot, wait for the mutex a bit longer.
+			if m.LockTimeout(time.Millisecond * 20) {
+				m.Unlock()
+			}
+		}()
+	}
+	if !a.True(waitForTickets(m, waiters+1)) {
+		return
+	}
+	time.Sleep(time.Millisecond * 50)
+	m.Unlock()
+	a.True(testutil.WaitForFunc(wg.Wait, time.Second*3))
+	a.True(m.TryLock())
+	m.Unlock()
+}
@@ -0,0 +1,147 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux freebsd
+
+package sync
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aybabtme/go-ipc/internal/helper"
+	"github.com/aybabtme/go-ipc/mmf"
+	"github.com/aybabtme/go-ipc/shm"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createPlacementRegion(t *testing.T, size int) *mmf.MemoryRegion {
+	shm.DestroyMemoryObject(testMemObj)
+	region, _, err := helper.CreateWritableRegion(testMemObj, os.O_CREATE|os.O_EXCL, 0666, size)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return region
+}
+
+func destroyPlacementRegion(t *testing.T, region *mmf.MemoryRegion) {
+	assert.NoError(t, region.Close())
+	assert.NoError(t, shm.DestroyMemoryObject(testMemObj))
+}
+
+func TestPlacementInvalidMemory(t *testing.T) {
+	a := assert.New(t)
+	mem := make([]byte, 32)
+	_, err := NewFutexMutexAt(mem[:2])
+	a.Error(err)
+	_, err = NewFutexMutexAt(mem[1:])
+	a.Error(err)
+	_, err = NewRWMutexAt(mem[4:])
+	a.Error(err)
+	_, err = NewEventAt(nil)
+	a.Error(err)
+}
+
+func TestFutexMutexAtMany(t *testing.T) {
+	const mutexes, routines, iters = 64, 8, 64 * 16
+	a := assert.New(t)
+	stride := FutexMutexStateSize + 8
+	region := createPlacementRegion(t, mutexes*stride)
+	defer destroyPlacementRegion(t, region)
+	data := region.Data()
+	lockers := make([]*FutexMutex, mutexes)
+	for i := range lockers {
+		var err error
+		lockers[i], err = NewFutexMutexAt(data[i*stride:])
+		if !a.NoError(err) {
+			return
+		}
+	}
+	counters := make([]int, mutexes)
+	var wg sync.WaitGroup
+	for r := 0; r < routines; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iters; i++ {
+				idx := i % mutexes
+				lockers[idx].Lock()
+				counters[idx]++
+				lockers[idx].Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	for i, cnt := range counters {
+		a.Equal(routines*iters/mutexes, cnt, "mutex %d", i)
+	}
+	for _, l := range lockers {
+		a.NoError(l.Close())
+	}
+}
+
+func TestRWMutexAt(t *testing.T) {
+	a := assert.New(t)
+	region := createPlacementRegion(t, RWMutexStateSize)
+	defer destroyPlacementRegion(t, region)
+	rw, err := NewRWMutexAt(region.Data())
+	if !a.NoError(err) {
+		return
+	}
+	defer rw.Close()
+	rw2, err := NewRWMutexAt(region.Data())
+	if !a.NoError(err) {
+		return
+	}
+	rw.RLock()
+	rw2.RLock()
+	locked := make(chan struct{})
+	go func() {
+		rw2.Lock()
+		close(locked)
+		rw2.Unlock()
+	}()
+	select {
+	case <-locked:
+		t.Error("writer acquired the lock held by readers")
+	case <-time.After(time.Millisecond * 50):
+	}
+	rw.RUnlock()
+	rw2.RUnlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Error("writer did not acquire the lock")
+	}
+	testLockerLock(t, func(string, int, os.FileMode) (IPCLocker, error) { return rw, nil }, nil)
+}
+
+func TestRWMutexAtUpgrade(t *testing.T) {
+	region := createPlacementRegion(t, RWMutexStateSize)
+	defer destroyPlacementRegion(t, region)
+	rw, err := NewRWMutexAt(region.Data())
+	if !assert.NoError(t, err) {
+		return
+	}
+	testRWMutexUpgrade(t, rw)
+}
+
+func TestEventAt(t *testing.T) {
+	a := assert.New(t)
+	region := createPlacementRegion(t, EventStateSize)
+	defer destroyPlacementRegion(t, region)
+	ev, err := NewEventAt(region.Data())
+	if !a.NoError(err) {
+		return
+	}
+	defer ev.Close()
+	a.False(ev.WaitTimeout(time.Millisecond * 10))
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		ev.Set()
+	}()
+	a.True(ev.WaitTimeout(time.Second))
+	a.False(ev.WaitTimeout(0))
+}
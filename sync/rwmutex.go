@@ -4,6 +4,8 @@ package sync
 
 import (
 	"os"
+	"sync"
+	"time"
 
 	"github.com/aybabtme/go-ipc/internal/allocator"
 	"github.com/aybabtme/go-ipc/internal/helper"
@@ -15,40 +17,81 @@ import (
 
 // all implementations must satisfy at least IPCLocker interface.
 var (
-	_ IPCLocker = (*RWMutex)(nil)
+	_ TimedIPCLocker      = (*RWMutex)(nil)
+	_ TimedFallibleLocker = (*RWMutex)(nil)
+)
+
+// RWPolicy defines, which waiters get an rwmutex first.
+type RWPolicy int
+
+const (
+	// RWPolicyBalanced is the default policy. New readers wait, if there are waiting writers,
+	// a writer passes the mutex to all waiting readers, and the last reader passes it to a waiting writer,
+	// so neither readers nor writers are starved.
+	RWPolicyBalanced RWPolicy = iota
+	// RWPolicyPreferWriters makes new readers wait, if there are waiting writers,
+	// and a writer passes the mutex to the next waiting writer. Readers can be starved.
+	RWPolicyPreferWriters
+	// RWPolicyPreferReaders lets new readers in, while the mutex is held by other readers,
+	// even if there are waiting writers. Writers can be starved.
+	RWPolicyPreferReaders
 )
 
 // RWMutex is a mutex, that can be held by any number of readers or one writer.
+// It also supports an upgradable read lock, which is held along with plain readers by one owner at a time,
+// and which can be atomically turned into the write lock.
 type RWMutex struct {
-	lwm    *lwRWMutex
-	region *mmf.MemoryRegion
-	wR, wW waitWaker
-	name   string
+	lwm     *lwRWMutex
+	region  *mmf.MemoryRegion
+	waiters *rwWaiters
+	name    string
 }
 
-// NewRWMutex returns new RWMutex
+// rwWaiters are waitWakers used by RWMutex for readers, writers, the upgrading reader,
+// and the lock, which serializes upgradable readers.
+type rwWaiters struct {
+	r, w, u, l waitWaker
+}
+
+// NewRWMutex returns new RWMutex with RWPolicyBalanced policy.
 //	name - object name.
 //	flag - flag is a combination of open flags from 'os' package.
 //	perm - object's permission bits.
 func NewRWMutex(name string, flag int, perm os.FileMode) (*RWMutex, error) {
+	return NewRWMutexPolicy(name, flag, perm, RWPolicyBalanced)
+}
+
+// NewRWMutexPolicy returns new RWMutex with the given policy.
+// The policy is stored in the shared state of the mutex, so it is ignored, if the mutex already exists.
+// The shared state has grown from 8 to 12 bytes with the upgradable read lock, so mutexes,
+// which were created by previous versions, must be destroyed before they are opened by this one.
+// The semaphores used by upgradable readers are created on first use.
+//	name - object name.
+//	flag - flag is a combination of open flags from 'os' package.
+//	perm - object's permission bits.
+//	policy - one of RWPolicy* constants.
+func NewRWMutexPolicy(name string, flag int, perm os.FileMode, policy RWPolicy) (*RWMutex, error) {
 	if err := ensureOpenFlags(flag); err != nil {
 		return nil, err
 	}
-	region, created, err := helper.CreateWritableRegion(mutexSharedStateName(name, "rw"), flag, perm, lwRWMStateSize)
+	if policy < RWPolicyBalanced || policy > RWPolicyPreferReaders {
+		return nil, errors.Errorf("invalid rwmutex policy %d", policy)
+	}
+	region, created, err := helper.CreateWritableRegion(mutexSharedStateName(name, "rw"), flag, perm, lwRWMStateSize+lwmStateSize)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create shared state")
 	}
 	result := &RWMutex{region: region, name: name}
-	if result.wR, result.wW, err = makeRWMWaiters(name, flag, perm); err != nil {
+	if result.waiters, err = makeRWMWaiters(name, flag, perm, created); err != nil {
 		region.Close()
 		if created {
 			shm.DestroyMemoryObject(mutexSharedStateName(name, "rw"))
 		}
 		return nil, err
 	}
-	result.lwm = newRWLightweightMutex(allocator.ByteSliceData(region.Data()), result.wR, result.wW)
+	result.lwm = newRWLightweightMutex(allocator.ByteSliceData(region.Data()), result.waiters)
 	if created {
-		result.lwm.init()
+		result.lwm.init(policy)
 	}
 	return result, nil
 }
@@ -63,20 +106,133 @@ func (rw *RWMutex) Unlock() {
 	rw.lwm.unlock()
 }
 
+// TryLock makes one attempt to lock the mutex exclusively. It return true on succeess and false otherwise.
+func (rw *RWMutex) TryLock() bool {
+	return rw.lwm.tryLock()
+}
+
+// LockTimeout tries to lock the mutex exclusively, waiting for not more, than timeout. It panics on an error.
+func (rw *RWMutex) LockTimeout(timeout time.Duration) bool {
+	result, err := rw.LockTimeoutErr(timeout)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
 // RLock locks the mutex for reading. It panics on an error.
 func (rw *RWMutex) RLock() {
 	rw.lwm.rlock()
 }
 
+// TryRLock makes one attempt to lock the mutex for reading. It return true on succeess and false otherwise.
+func (rw *RWMutex) TryRLock() bool {
+	return rw.lwm.tryRLock()
+}
+
+// RLockTimeout tries to lock the mutex for reading, waiting for not more, than timeout. It panics on an error.
+func (rw *RWMutex) RLockTimeout(timeout time.Duration) bool {
+	result, err := rw.RLockTimeoutErr(timeout)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
 // RUnlock desceases the number of mutex's readers. If it becomes 0, writers (if any) can proceed.
 // It panics on an error, or if the mutex is not locked.
 func (rw *RWMutex) RUnlock() {
 	rw.lwm.runlock()
 }
 
+// UpgradableLock locks the mutex for reading, so that it can be upgraded to the write lock later.
+// Only one upgradable reader can hold the mutex at a time, but it does not exclude plain readers.
+// It panics on an error.
+func (rw *RWMutex) UpgradableLock() {
+	if err := rw.UpgradableLockErr(); err != nil {
+		panic(err)
+	}
+}
+
+// UpgradableUnlock releases the upgradable read lock. It panics on an error, or if the mutex is not locked.
+func (rw *RWMutex) UpgradableUnlock() {
+	if err := rw.UpgradableUnlockErr(); err != nil {
+		panic(err)
+	}
+}
+
+// Upgrade atomically turns the upgradable read lock into the write lock.
+// It prevents new readers and writers from taking the mutex, and waits for the remaining readers to leave.
+// The mutex must be held with UpgradableLock, and, after the upgrade, it must be released with Unlock.
+// It panics on an error.
+func (rw *RWMutex) Upgrade() {
+	if err := rw.UpgradeErr(); err != nil {
+		panic(err)
+	}
+}
+
+// LockErr locks the mutex exclusively. It returns an error, if the operation failed.
+func (rw *RWMutex) LockErr() error {
+	return rw.lwm.lockErr()
+}
+
+// LockTimeoutErr tries to lock the mutex exclusively, waiting for not more, than timeout.
+// It returns false and a nil error, if the timeout has elapsed.
+func (rw *RWMutex) LockTimeoutErr(timeout time.Duration) (bool, error) {
+	return rw.lwm.lockTimeoutErr(timeout)
+}
+
+// UnlockErr releases the mutex. It returns ErrNotLocked, if the mutex is not locked.
+func (rw *RWMutex) UnlockErr() error {
+	return rw.lwm.unlockErr()
+}
+
+// RLockErr locks the mutex for reading. It returns an error, if the operation failed.
+func (rw *RWMutex) RLockErr() error {
+	return rw.lwm.rlockErr()
+}
+
+// RLockTimeoutErr tries to lock the mutex for reading, waiting for not more, than timeout.
+// It returns false and a nil error, if the timeout has elapsed.
+func (rw *RWMutex) RLockTimeoutErr(timeout time.Duration) (bool, error) {
+	return rw.lwm.rlockTimeoutErr(timeout)
+}
+
+// UpgradableLockErr locks the mutex for reading, so that it can be upgraded to the write lock later.
+// It returns an error, if the operation failed.
+func (rw *RWMutex) UpgradableLockErr() error {
+	return rw.lwm.upgradableLockErr()
+}
+
+// UpgradableUnlockErr releases the upgradable read lock.
+// It returns ErrNotLocked, if the mutex is not locked for reading.
+func (rw *RWMutex) UpgradableUnlockErr() error {
+	return rw.lwm.upgradableUnlockErr()
+}
+
+// UpgradeErr atomically turns the upgradable read lock into the write lock.
+// It returns ErrNotLocked, if the mutex is not locked for reading, and an error, if the operation failed.
+func (rw *RWMutex) UpgradeErr() error {
+	return rw.lwm.upgradeErr()
+}
+
+// RUnlockErr desceases the number of mutex's readers.
+// It returns ErrNotLocked, if the mutex is not locked for reading.
+func (rw *RWMutex) RUnlockErr() error {
+	return rw.lwm.runlockErr()
+}
+
+// Policy returns the policy of the mutex.
+func (rw *RWMutex) Policy() RWPolicy {
+	return rw.lwm.policy()
+}
+
 // Close closes shared state of the mutex.
 func (rw *RWMutex) Close() error {
-	e1, e2 := closeRWWaiters(rw.wR, rw.wW), rw.region.Close()
+	if rw.region == nil {
+		return nil
+	}
+	e1, e2 := closeRWWaiters(rw.waiters), rw.region.Close()
 	if e1 != nil {
 		return e1
 	}
@@ -88,6 +244,9 @@ func (rw *RWMutex) Close() error {
 
 // Destroy closes the mutex and removes it permanently.
 func (rw *RWMutex) Destroy() error {
+	if rw.region == nil {
+		return nil
+	}
 	if err := rw.Close(); err != nil {
 		return errors.Wrap(err, "failed to close shared state")
 	}
@@ -109,6 +268,7 @@ func DestroyRWMutex(name string) error {
 
 // RLocker returns a Locker interface that implements
 // the Lock and Unlock methods by calling rw.RLock and rw.RUnlock.
+// The result also implements FallibleLocker.
 func (rw *RWMutex) RLocker() IPCLocker {
 	return (*rlocker)(rw)
 }
@@ -119,39 +279,118 @@ func (r *rlocker) Lock()        { (*RWMutex)(r).RLock() }
 func (r *rlocker) Unlock()      { (*RWMutex)(r).RUnlock() }
 func (r *rlocker) Close() error { return (*RWMutex)(r).Close() }
 
-func makeRWMWaiters(name string, flag int, perm os.FileMode) (waitWaker, waitWaker, error) {
-	rSema, err := NewSemaphore(name+".rs", flag, perm, 0)
-	if err != nil {
-		return nil, nil, errors.Wrap(err, "failed to create r/sema")
+func (r *rlocker) LockErr() error   { return (*RWMutex)(r).RLockErr() }
+func (r *rlocker) UnlockErr() error { return (*RWMutex)(r).RUnlockErr() }
+
+// rwWaiterSuffixes are the suffixes of the semaphores of the readers, writers,
+// the upgrading reader and the upgradable readers lock.
+var rwWaiterSuffixes = [...]string{".rs", ".ws", ".us", ".ls"}
+
+// rwLazyWaiters is the number of the last semaphores from rwWaiterSuffixes,
+// which are used only by upgradable readers, so they are opened on first use.
+const rwLazyWaiters = 2
+
+func makeRWMWaiters(name string, flag int, perm os.FileMode, created bool) (*rwWaiters, error) {
+	var waiters [len(rwWaiterSuffixes)]waitWaker
+	eager := len(rwWaiterSuffixes) - rwLazyWaiters
+	for i, suffix := range rwWaiterSuffixes[:eager] {
+		s, err := NewSemaphore(name+suffix, flag, perm, 0)
+		if err != nil {
+			for j := 0; j < i; j++ {
+				waiters[j].(*semaWaiter).s.Close()
+				DestroySemaphore(name + rwWaiterSuffixes[j])
+			}
+			return nil, errors.Wrapf(err, "failed to create %s sema", suffix)
+		}
+		waiters[i] = newSemaWaiter(s)
+	}
+	for i, suffix := range rwWaiterSuffixes[eager:] {
+		// the semaphores of a new mutex must not be left by its previous instance.
+		if created {
+			if err := DestroySemaphore(name + suffix); err != nil {
+				closeWaiters(waiters[:eager])
+				return nil, errors.Wrapf(err, "failed to destroy %s sema", suffix)
+			}
+		}
+		waiters[eager+i] = &lazySemaWaiter{name: name + suffix, perm: perm}
+	}
+	return &rwWaiters{r: waiters[0], w: waiters[1], u: waiters[2], l: waiters[3]}, nil
+}
+
+func closeRWWaiters(w *rwWaiters) error {
+	return closeWaiters([]waitWaker{w.r, w.w, w.u, w.l})
+}
+
+func closeWaiters(waiters []waitWaker) error {
+	var result error
+	for i, ww := range waiters {
+		var err error
+		switch typed := ww.(type) {
+		case *semaWaiter:
+			err = typed.s.Close()
+		case *lazySemaWaiter:
+			err = typed.close()
+		}
+		if err != nil && result == nil {
+			result = errors.Wrapf(err, "failed to close %s sema", rwWaiterSuffixes[i])
+		}
 	}
-	wSema, err := NewSemaphore(name+".ws", flag, perm, 0)
+	return result
+}
+
+// lazySemaWaiter is a semaWaiter, which opens its semaphore on first use.
+// Both waiters and wakers open the semaphore with O_CREATE, so they always use the same one.
+type lazySemaWaiter struct {
+	name string
+	perm os.FileMode
+	mut  sync.Mutex
+	sw   *semaWaiter
+}
+
+func (w *lazySemaWaiter) get() (*semaWaiter, error) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	if w.sw == nil {
+		s, err := NewSemaphore(w.name, os.O_CREATE, w.perm, 0)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open %q sema", w.name)
+		}
+		w.sw = newSemaWaiter(s)
+	}
+	return w.sw, nil
+}
+
+func (w *lazySemaWaiter) wake(count int32) (int, error) {
+	sw, err := w.get()
 	if err != nil {
-		rSema.Close()
-		DestroySemaphore(name + ".rs")
-		return nil, nil, errors.Wrap(err, "failed to create w/sema")
+		return 0, err
 	}
-	return newSemaWaiter(rSema), newSemaWaiter(wSema), nil
+	return sw.wake(count)
 }
 
-func closeRWWaiters(wR, wW waitWaker) error {
-	sR, sW := wR.(*semaWaiter).s, wW.(*semaWaiter).s
-	e1, e2 := sR.Close(), sW.Close()
-	if e1 != nil {
-		return errors.Wrap(e1, "failed to close r/sema")
+func (w *lazySemaWaiter) wait(value int32, timeout time.Duration) error {
+	sw, err := w.get()
+	if err != nil {
+		return err
 	}
-	if e2 != nil {
-		return errors.Wrap(e2, "failed to close w/sema")
+	return sw.wait(value, timeout)
+}
+
+func (w *lazySemaWaiter) close() error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	if w.sw == nil {
+		return nil
 	}
-	return nil
+	return w.sw.s.Close()
 }
 
 func destroyRWWaiters(name string) error {
-	e1, e2 := DestroySemaphore(name+".rs"), DestroySemaphore(name+".ws")
-	if e1 != nil {
-		return errors.Wrap(e1, "failed to deatroy r/sema")
-	}
-	if e2 != nil {
-		return errors.Wrap(e2, "failed to deatroy w/sema")
+	var result error
+	for _, suffix := range rwWaiterSuffixes {
+		if err := DestroySemaphore(name + suffix); err != nil && result == nil {
+			result = errors.Wrapf(err, "failed to destroy %s sema", suffix)
+		}
 	}
-	return nil
+	return result
 }
@@ -0,0 +1,40 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux freebsd
+
+package sync
+
+import (
+	"github.com/aybabtme/go-ipc/internal/allocator"
+)
+
+const (
+	// RWMutexStateSize is the size of the memory needed by NewRWMutexAt.
+	// It contains mutex state, the lock of upgradable readers,
+	// and three futex-based semaphores for readers, writers and the upgrading reader.
+	// It has grown from 16 to 24 bytes with the upgradable read lock, and the layout has changed,
+	// so the memory of mutexes placed by previous versions must not be used with this one.
+	RWMutexStateSize = lwRWMStateSize + lwmStateSize + 12
+)
+
+// NewRWMutexAt creates a rw mutex, which state is stored in the given memory.
+// Unlike NewRWMutex, it does not use named semaphores for waiting, but futexes.
+// Zeroed memory is an unlocked mutex.
+// The caller must keep the memory mapped, while the mutex is in use.
+// Close and Destroy are no-op for such mutexes.
+//	mem - at least RWMutexStateSize bytes, 8-byte aligned.
+func NewRWMutexAt(mem []byte) (*RWMutex, error) {
+	if err := checkPlacement(mem, RWMutexStateSize, 8); err != nil {
+		return nil, err
+	}
+	data := allocator.ByteSliceData(mem)
+	lockState := allocator.AdvancePointer(data, lwRWMStateSize)
+	semas := allocator.AdvancePointer(lockState, lwmStateSize)
+	waiters := &rwWaiters{
+		r: newFutexSema(semas),
+		w: newFutexSema(allocator.AdvancePointer(semas, 4)),
+		u: newFutexSema(allocator.AdvancePointer(semas, 8)),
+		l: &futex{ptr: lockState},
+	}
+	return &RWMutex{waiters: waiters, lwm: newRWLightweightMutex(data, waiters)}, nil
+}
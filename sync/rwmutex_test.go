@@ -7,7 +7,14 @@ import (
 	"math/rand"
 	"os"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	testutil "github.com/aybabtme/go-ipc/internal/test"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 )
 
 func rwMutexCtor(name string, flag int, perm os.FileMode) (IPCLocker, error) {
@@ -70,6 +77,423 @@ func TestRWMutexPanicsOnDoubleRUnlock(t *testing.T) {
 	testLockerTwiceUnlock(t, rwRMutexCtor, rwMutexDtor)
 }
 
+func TestRWMutexErr(t *testing.T) {
+	testLockerErr(t, rwMutexCtor, rwMutexDtor)
+}
+
+func TestRWMutexRErr(t *testing.T) {
+	testLockerErr(t, rwRMutexCtor, rwMutexDtor)
+}
+
+func TestRWMutexLockTimeout(t *testing.T) {
+	testLockerLockTimeout(t, "rw", rwMutexCtor, rwMutexDtor)
+}
+
+func TestRWMutexLockTimeout2(t *testing.T) {
+	testLockerLockTimeout2(t, "rw", rwMutexCtor, rwMutexDtor)
+}
+
+func makeTestRWMutex(t *testing.T, policy RWPolicy) *RWMutex {
+	a := assert.New(t)
+	a.NoError(DestroyRWMutex(testLockerName))
+	m, err := NewRWMutexPolicy(testLockerName, os.O_CREATE|os.O_EXCL, 0666, policy)
+	if !a.NoError(err) {
+		t.FailNow()
+	}
+	return m
+}
+
+// waitForRWState waits until the state of the mutex satisfies f.
+func waitForRWState(m *RWMutex, f func(s lwRWState) bool) bool {
+	return testutil.WaitForFunc(func() {
+		for !f(lwRWState(atomic.LoadUint64(m.lwm.state))) {
+			time.Sleep(time.Millisecond)
+		}
+	}, time.Second*3)
+}
+
+func TestRWMutexInvalidPolicy(t *testing.T) {
+	a := assert.New(t)
+	_, err := NewRWMutexPolicy(testLockerName, os.O_CREATE, 0666, RWPolicy(10))
+	a.Error(err)
+}
+
+func TestRWMutexPolicyIsShared(t *testing.T) {
+	a := assert.New(t)
+	m := makeTestRWMutex(t, RWPolicyPreferReaders)
+	defer m.Destroy()
+	m2, err := NewRWMutex(testLockerName, 0, 0666)
+	if !a.NoError(err) {
+		return
+	}
+	defer m2.Close()
+	a.Equal(RWPolicyPreferReaders, m2.Policy())
+}
+
+func TestRWMutexTryLock(t *testing.T) {
+	a := assert.New(t)
+	m := makeTestRWMutex(t, RWPolicyBalanced)
+	defer m.Destroy()
+	a.True(m.TryRLock())
+	a.True(m.TryRLock())
+	a.False(m.TryLock())
+	m.RUnlock()
+	m.RUnlock()
+	a.True(m.TryLock())
+	a.False(m.TryRLock())
+	a.False(m.TryLock())
+	m.Unlock()
+	a.Equal(uint64(0), atomic.LoadUint64(m.lwm.state))
+}
+
+func TestRWMutexRLockTimeout(t *testing.T) {
+	a := assert.New(t)
+	m := makeTestRWMutex(t, RWPolicyBalanced)
+	defer m.Destroy()
+	m.Lock()
+	a.False(m.RLockTimeout(time.Millisecond * 50))
+	m.Unlock()
+	a.True(m.RLockTimeout(time.Millisecond * 50))
+	a.False(m.LockTimeout(time.Millisecond * 50))
+	m.RUnlock()
+	a.Equal(uint64(0), atomic.LoadUint64(m.lwm.state))
+}
+
+// testRWMutexReaderWithWaitingWriter checks, if a new reader can join other readers, while a writer is waiting.
+func testRWMutexReaderWithWaitingWriter(t *testing.T, policy RWPolicy, canJoin bool) {
+	a := assert.New(t)
+	m := makeTestRWMutex(t, policy)
+	defer m.Destroy()
+	m.RLock()
+	writerDone := make(chan bool)
+	go func() {
+		locked := m.LockTimeout(time.Second * 3)
+		if locked {
+			m.Unlock()
+		}
+		writerDone <- locked
+	}()
+	if !a.True(waitForRWState(m, func(s lwRWState) bool { return s.waitingWriters() == 1 })) {
+		return
+	}
+	a.Equal(canJoin, m.TryRLock())
+	if canJoin {
+		m.RUnlock()
+	}
+	m.RUnlock()
+	a.True(<-writerDone)
+}
+
+func TestRWMutexReaderWithWaitingWriter(t *testing.T) {
+	t.Run("balanced", func(t *testing.T) {
+		testRWMutexReaderWithWaitingWriter(t, RWPolicyBalanced, false)
+	})
+	t.Run("writers", func(t *testing.T) {
+		testRWMutexReaderWithWaitingWriter(t, RWPolicyPreferWriters, false)
+	})
+	t.Run("readers", func(t *testing.T) {
+		testRWMutexReaderWithWaitingWriter(t, RWPolicyPreferReaders, true)
+	})
+}
+
+// testRWMutexWriterUnlock checks, who gets the mutex after a writer, if there are waiting readers and writers.
+func testRWMutexWriterUnlock(t *testing.T, policy RWPolicy, writerFirst bool) {
+	a := assert.New(t)
+	m := makeTestRWMutex(t, policy)
+	defer m.Destroy()
+	m.Lock()
+	order := make(chan string, 2)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	defer wg.Wait()
+	go func() {
+		defer wg.Done()
+		m.Lock()
+		order <- "writer"
+		m.Unlock()
+	}()
+	go func() {
+		defer wg.Done()
+		m.RLock()
+		order <- "reader"
+		m.RUnlock()
+	}()
+	if !a.True(waitForRWState(m, func(s lwRWState) bool { return s.waitingWriters() == 1 && s.waitingReaders() == 1 })) {
+		m.Unlock()
+		return
+	}
+	m.Unlock()
+	expected := []string{"reader", "writer"}
+	if writerFirst {
+		expected[0], expected[1] = expected[1], expected[0]
+	}
+	a.Equal(expected, []string{<-order, <-order})
+}
+
+func TestRWMutexWriterUnlock(t *testing.T) {
+	t.Run("balanced", func(t *testing.T) {
+		testRWMutexWriterUnlock(t, RWPolicyBalanced, false)
+	})
+	t.Run("writers", func(t *testing.T) {
+		testRWMutexWriterUnlock(t, RWPolicyPreferWriters, true)
+	})
+	t.Run("readers", func(t *testing.T) {
+		testRWMutexWriterUnlock(t, RWPolicyPreferReaders, false)
+	})
+}
+
+func TestLwRWStateGrant(t *testing.T) {
+	a := assert.New(t)
+	// no readers left, one waiting writer and two waiting readers.
+	makeState := func(policy RWPolicy) lwRWState {
+		s := lwRWState(uint64(policy) << lwRWMPolicyShift)
+		s.addWaitingWriters(1)
+		s.addWaitingReaders(2)
+		return s
+	}
+	for _, tc := range []struct {
+		policy         RWPolicy
+		writerReleased bool
+		expected       lwRWGrant
+	}{
+		{RWPolicyBalanced, false, lwRWGrant{writer: true}},
+		{RWPolicyBalanced, true, lwRWGrant{readers: 2}},
+		{RWPolicyPreferWriters, false, lwRWGrant{writer: true}},
+		{RWPolicyPreferWriters, true, lwRWGrant{writer: true}},
+		{RWPolicyPreferReaders, false, lwRWGrant{readers: 2}},
+		{RWPolicyPreferReaders, true, lwRWGrant{readers: 2}},
+	} {
+		s := makeState(tc.policy)
+		a.Equal(tc.expected, s.grant(tc.writerReleased), "policy %d, writer released: %v", tc.policy, tc.writerReleased)
+		if tc.expected.writer {
+			a.True(s.writer())
+			a.Equal(int64(0), s.waitingWriters())
+			a.Equal(int64(2), s.waitingReaders())
+		} else {
+			a.Equal(int64(2), s.readers())
+			a.Equal(int64(0), s.waitingReaders())
+		}
+	}
+}
+
+// TestRWMutexReadersDoNotStarveWriter checks, that readers, which keep arriving
+// while a writer waits, do not get the mutex before the writer.
+func TestRWMutexReadersDoNotStarveWriter(t *testing.T) {
+	a := assert.New(t)
+	m := makeTestRWMutex(t, RWPolicyBalanced)
+	defer m.Destroy()
+	m.RLock()
+	order := make(chan string, 16)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.Lock()
+		order <- "writer"
+		m.Unlock()
+	}()
+	if !a.True(waitForRWState(m, func(s lwRWState) bool { return s.waitingWriters() == 1 })) {
+		m.RUnlock()
+		return
+	}
+	const readers = 8
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.RLock()
+			order <- "reader"
+			m.RUnlock()
+		}()
+	}
+	if !a.True(waitForRWState(m, func(s lwRWState) bool { return s.waitingReaders() == readers })) {
+		m.RUnlock()
+		return
+	}
+	m.RUnlock()
+	a.Equal("writer", <-order)
+	for i := 0; i < readers; i++ {
+		a.Equal("reader", <-order)
+	}
+}
+
+func TestRWMutexCancelledWriterLetsReadersIn(t *testing.T) {
+	a := assert.New(t)
+	m := makeTestRWMutex(t, RWPolicyBalanced)
+	defer m.Destroy()
+	m.RLock()
+	writerDone := make(chan bool)
+	go func() {
+		writerDone <- m.LockTimeout(time.Millisecond * 100)
+	}()
+	if !a.True(waitForRWState(m, func(s lwRWState) bool { return s.waitingWriters() == 1 })) {
+		return
+	}
+	readerDone := make(chan bool)
+	go func() {
+		readerDone <- m.RLockTimeout(time.Second * 3)
+	}()
+	if !a.True(waitForRWState(m, func(s lwRWState) bool { return s.waitingReaders() == 1 })) {
+		return
+	}
+	a.False(<-writerDone)
+	a.True(<-readerDone)
+	m.RUnlock()
+	m.RUnlock()
+	a.Equal(uint64(0), atomic.LoadUint64(m.lwm.state))
+}
+
+func TestRWMutexTimeoutStress(t *testing.T) {
+	const (
+		workers    = 16
+		iterations = 500
+	)
+	for _, policy := range []RWPolicy{RWPolicyBalanced, RWPolicyPreferWriters, RWPolicyPreferReaders} {
+		a := assert.New(t)
+		m := makeTestRWMutex(t, policy)
+		var readers, writers int32
+		var wg sync.WaitGroup
+		wg.Add(workers)
+		for i := 0; i < workers; i++ {
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < iterations; j++ {
+					timeout := time.Duration(rand.Intn(100)) * time.Microsecond
+					if (i+j)%3 == 0 {
+						if m.LockTimeout(timeout) {
+							a.Equal(int32(1), atomic.AddInt32(&writers, 1))
+							a.Equal(int32(0), atomic.LoadInt32(&readers))
+							atomic.AddInt32(&writers, -1)
+							m.Unlock()
+						}
+					} else if m.RLockTimeout(timeout) {
+						atomic.AddInt32(&readers, 1)
+						a.Equal(int32(0), atomic.LoadInt32(&writers))
+						atomic.AddInt32(&readers, -1)
+						m.RUnlock()
+					}
+				}
+			}(i)
+		}
+		a.True(testutil.WaitForFunc(wg.Wait, time.Second*20))
+		// no wakeups must be left in the semaphores.
+		a.Equal(uint64(policy)<<lwRWMPolicyShift, atomic.LoadUint64(m.lwm.state))
+		a.True(m.TryLock())
+		a.False(m.LockTimeout(time.Millisecond))
+		a.False(m.RLockTimeout(time.Millisecond))
+		m.Unlock()
+		a.NoError(m.Destroy())
+	}
+}
+
+func testRWMutexUpgrade(t *testing.T, m *RWMutex) {
+	a := assert.New(t)
+	m.UpgradableLock()
+	// plain readers coexist with the upgradable one, but other upgradable readers wait.
+	a.True(m.TryRLock())
+	upgradable := make(chan struct{})
+	go func() {
+		m.UpgradableLock()
+		close(upgradable)
+		m.UpgradableUnlock()
+	}()
+	upgraded := make(chan struct{})
+	go func() {
+		m.Upgrade()
+		close(upgraded)
+	}()
+	if !a.True(waitForRWState(m, func(s lwRWState) bool { return s.upgrading() })) {
+		return
+	}
+	// the pending upgrade stops new readers and writers.
+	a.False(m.TryRLock())
+	a.False(m.TryLock())
+	select {
+	case <-upgraded:
+		t.Error("the mutex has been upgraded, while it is held by a reader")
+	case <-time.After(time.Millisecond * 50):
+	}
+	m.RUnlock()
+	select {
+	case <-upgraded:
+	case <-time.After(time.Second * 3):
+		t.Error("the mutex has not been upgraded")
+		return
+	}
+	select {
+	case <-upgradable:
+		t.Error("upgradable reader acquired the mutex held by the writer")
+	case <-time.After(time.Millisecond * 50):
+	}
+	m.Unlock()
+	select {
+	case <-upgradable:
+	case <-time.After(time.Second * 3):
+		t.Error("upgradable reader has not acquired the mutex")
+	}
+}
+
+func TestRWMutexUpgrade(t *testing.T) {
+	m := makeTestRWMutex(t, RWPolicyBalanced)
+	defer m.Destroy()
+	testRWMutexUpgrade(t, m)
+}
+
+func TestRWMutexUpgradeWithoutReaders(t *testing.T) {
+	a := assert.New(t)
+	m := makeTestRWMutex(t, RWPolicyPreferReaders)
+	defer m.Destroy()
+	a.Equal(ErrNotLocked, m.UpgradeErr())
+	a.NoError(m.UpgradableLockErr())
+	a.NoError(m.UpgradeErr())
+	a.False(m.TryRLock())
+	a.NoError(m.UnlockErr())
+	a.NoError(m.UpgradableLockErr())
+	a.NoError(m.UpgradableUnlockErr())
+	a.Equal(ErrNotLocked, m.UpgradableUnlockErr())
+	a.True(m.TryLock())
+	m.Unlock()
+}
+
+func TestRWMutexUpgradeWaitersAreLazy(t *testing.T) {
+	a := assert.New(t)
+	m := makeTestRWMutex(t, RWPolicyBalanced)
+	defer DestroyRWMutex(testLockerName)
+	semaExists := func(suffix string) bool {
+		s, err := NewSemaphore(testLockerName+suffix, 0, 0666, 0)
+		if err != nil {
+			a.True(os.IsNotExist(errors.Cause(err)))
+			return false
+		}
+		a.NoError(s.Close())
+		return true
+	}
+	m.Lock()
+	m.Unlock()
+	m.RLock()
+	m.RUnlock()
+	a.False(semaExists(".us"))
+	a.False(semaExists(".ls"))
+	// the upgrading reader waits for another reader to leave.
+	a.True(m.TryRLock())
+	m.UpgradableLock()
+	upgraded := make(chan struct{})
+	go func() {
+		m.Upgrade()
+		close(upgraded)
+	}()
+	if !a.True(waitForRWState(m, func(s lwRWState) bool { return s.upgrading() })) {
+		return
+	}
+	m.RUnlock()
+	<-upgraded
+	m.Unlock()
+	a.True(semaExists(".us"))
+	a.NoError(m.Destroy())
+	a.False(semaExists(".us"))
+}
+
 func ExampleRWMutex() {
 	const (
 		writers = 4
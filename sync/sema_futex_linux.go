@@ -0,0 +1,169 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package sync
+
+import (
+	"math"
+	"os"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/aybabtme/go-ipc/internal/allocator"
+	"github.com/aybabtme/go-ipc/internal/common"
+	"github.com/aybabtme/go-ipc/internal/helper"
+	"github.com/aybabtme/go-ipc/mmf"
+	"github.com/aybabtme/go-ipc/shm"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	futexSemaStateSize = int(unsafe.Sizeof(futexSemaState{}))
+)
+
+// futexSemaState is a shared state of a futex-based semaphore.
+// value is the futex word. The waiters counters allow Signal not to make a syscall,
+// if there are no waiters. Waiters of several units are counted separately,
+// as they can't be woken one by one: a woken waiter may need more units, than there are,
+// while a waiter of a single unit keeps sleeping.
+type futexSemaState struct {
+	value        int32
+	waiters      int32
+	multiWaiters int32
+}
+
+// futexSemaphore is a semaphore, which value is stored in shared memory.
+// Uncontended operations do not make any syscalls.
+type futexSemaphore struct {
+	name   string
+	region *mmf.MemoryRegion
+	state  *futexSemaState
+}
+
+func newFutexSemaphore(name string, flag int, perm os.FileMode, initial int) (*futexSemaphore, error) {
+	if err := ensureOpenFlags(flag); err != nil {
+		return nil, err
+	}
+	if initial < 0 {
+		return nil, errors.Errorf("invalid initial semaphore value %d", initial)
+	}
+	region, created, err := helper.CreateWritableRegion(futexSemaName(name), flag, perm, futexSemaStateSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create shared state")
+	}
+	result := &futexSemaphore{
+		name:   name,
+		region: region,
+		state:  (*futexSemaState)(allocator.ByteSliceData(region.Data())),
+	}
+	if created {
+		atomic.StoreInt32(&result.state.value, int32(initial))
+	}
+	return result, nil
+}
+
+func (s *futexSemaphore) signal(count int) error {
+	if count < 0 || count > math.MaxInt32 {
+		return errors.Errorf("invalid semaphore count %d", count)
+	}
+	for {
+		value := atomic.LoadInt32(&s.state.value)
+		if int64(value)+int64(count) > math.MaxInt32 {
+			return errors.New("semaphore value overflow")
+		}
+		if atomic.CompareAndSwapInt32(&s.state.value, value, value+int32(count)) {
+			break
+		}
+	}
+	// the value has changed, so a waiter, which is about to sleep, won't miss the wakeup.
+	var err error
+	if atomic.LoadInt32(&s.state.multiWaiters) > 0 {
+		_, err = s.futex().wakeAll()
+	} else if atomic.LoadInt32(&s.state.waiters) > 0 {
+		_, err = s.futex().wake(int32(count))
+	}
+	return err
+}
+
+func (s *futexSemaphore) tryWaitN(n int32) bool {
+	for {
+		value := atomic.LoadInt32(&s.state.value)
+		if value < n {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&s.state.value, value, value-n) {
+			return true
+		}
+	}
+}
+
+func (s *futexSemaphore) waitN(n int, timeout time.Duration) (bool, error) {
+	if s.tryWaitN(int32(n)) {
+		return true, nil
+	}
+	if timeout == 0 {
+		return false, nil
+	}
+	counter := &s.state.waiters
+	if n > 1 {
+		counter = &s.state.multiWaiters
+	}
+	atomic.AddInt32(counter, 1)
+	defer atomic.AddInt32(counter, -1)
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		value := atomic.LoadInt32(&s.state.value)
+		if value >= int32(n) {
+			if atomic.CompareAndSwapInt32(&s.state.value, value, value-int32(n)) {
+				return true, nil
+			}
+			continue
+		}
+		// the wait returns immediately, if the value has changed since it was loaded.
+		if err := s.futex().wait(value, timeout); err != nil {
+			if !common.IsTimeoutErr(err) {
+				return false, err
+			}
+			return s.tryWaitN(int32(n)), nil
+		}
+		if timeout > 0 {
+			if timeout = time.Until(deadline); timeout <= 0 {
+				return s.tryWaitN(int32(n)), nil
+			}
+		}
+	}
+}
+
+func (s *futexSemaphore) value() (int, error) {
+	return int(atomic.LoadInt32(&s.state.value)), nil
+}
+
+func (s *futexSemaphore) futex() *futex {
+	return &futex{ptr: unsafe.Pointer(&s.state.value)}
+}
+
+func (s *futexSemaphore) close() error {
+	return s.region.Close()
+}
+
+func (s *futexSemaphore) destroy() error {
+	if err := s.close(); err != nil {
+		return errors.Wrap(err, "failed to close shared state")
+	}
+	return destroyFutexSemaphore(s.name)
+}
+
+func destroyFutexSemaphore(name string) error {
+	if err := shm.DestroyMemoryObject(futexSemaName(name)); err != nil {
+		return errors.Wrap(err, "failed to destroy shared state")
+	}
+	return nil
+}
+
+func futexSemaName(name string) string {
+	return name + ".sem"
+}
@@ -0,0 +1,158 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+package sync
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type semaCtor func(name string, flag int, initial int) (*Semaphore, error)
+
+func newFutexTestSemaphore(name string, flag int, initial int) (*Semaphore, error) {
+	s, err := newFutexSemaphore(name, flag, 0666, initial)
+	if err != nil {
+		return nil, err
+	}
+	return (*Semaphore)(&semaphore{s}), nil
+}
+
+func createTestSemaphore(a *assert.Assertions, ctor semaCtor, initial int) *Semaphore {
+	destroyFutexSemaphore(testSemaName)
+	destroySysVSemaphore(testSemaName)
+	s, err := ctor(testSemaName, os.O_CREATE|os.O_EXCL, initial)
+	if !a.NoError(err) {
+		return nil
+	}
+	return s
+}
+
+func TestFutexSemaMixedWaiters(t *testing.T) {
+	a := assert.New(t)
+	s := createTestSemaphore(a, newFutexTestSemaphore, 0)
+	if s == nil {
+		return
+	}
+	defer s.Destroy()
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		s.WaitN(2, -1)
+	}()
+	for i := 0; i < 3; i++ {
+		go func() {
+			defer wg.Done()
+			s.Wait()
+		}()
+	}
+	time.Sleep(time.Millisecond * 50)
+	// single units must reach single-unit waiters, even if the multi-unit waiter is woken first.
+	for i := 0; i < 3; i++ {
+		s.Signal(1)
+		time.Sleep(time.Millisecond * 10)
+	}
+	s.Signal(2)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 3):
+		t.Errorf("timeout")
+		s.Signal(5)
+		<-done
+	}
+	a.Equal(0, s.Value())
+}
+
+func TestFutexSemaContention(t *testing.T) {
+	const (
+		jobs  = 8
+		iters = 10000
+	)
+	a := assert.New(t)
+	s := createTestSemaphore(a, newFutexTestSemaphore, 2)
+	if s == nil {
+		return
+	}
+	defer s.Destroy()
+	var wg sync.WaitGroup
+	wg.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iters; j++ {
+				s.Wait()
+				s.Signal(1)
+			}
+		}()
+	}
+	wg.Wait()
+	a.Equal(2, s.Value())
+}
+
+func TestFutexSemaSignalErr(t *testing.T) {
+	a := assert.New(t)
+	s := createTestSemaphore(a, newFutexTestSemaphore, 0)
+	if s == nil {
+		return
+	}
+	defer s.Destroy()
+	a.Error(s.SignalErr(-1))
+	a.NoError(s.SignalErr(0))
+	a.Equal(0, s.Value())
+}
+
+func benchmarkSemaUncontended(b *testing.B, ctor semaCtor) {
+	a := assert.New(b)
+	s := createTestSemaphore(a, ctor, 1)
+	if s == nil {
+		return
+	}
+	defer s.Destroy()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Wait()
+		s.Signal(1)
+	}
+}
+
+func benchmarkSemaContended(b *testing.B, ctor semaCtor) {
+	a := assert.New(b)
+	s := createTestSemaphore(a, ctor, 2)
+	if s == nil {
+		return
+	}
+	defer s.Destroy()
+	b.SetParallelism(4)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Wait()
+			s.Signal(1)
+		}
+	})
+}
+
+func BenchmarkFutexSemaUncontended(b *testing.B) {
+	benchmarkSemaUncontended(b, newFutexTestSemaphore)
+}
+
+func BenchmarkSysVSemaUncontended(b *testing.B) {
+	benchmarkSemaUncontended(b, newSysVTestSemaphore)
+}
+
+func BenchmarkFutexSemaContended(b *testing.B) {
+	benchmarkSemaContended(b, newFutexTestSemaphore)
+}
+
+func BenchmarkSysVSemaContended(b *testing.B) {
+	benchmarkSemaContended(b, newSysVTestSemaphore)
+}
@@ -0,0 +1,24 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux,!sysv_sema_linux
+
+package sync
+
+import "os"
+
+func newDefaultSemaphore(name string, flag int, perm os.FileMode, initial int) (semaImpl, error) {
+	s, err := newFutexSemaphore(name, flag, perm, initial)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// destroySemaphore permanently removes semaphore with the given name.
+// A sysV semaphore with this name may exist, if it was opened with SEM_UNDO flag, so it is removed too.
+func destroySemaphore(name string) error {
+	if err := destroyFutexSemaphore(name); err != nil {
+		return err
+	}
+	return destroySysVSemaphore(name)
+}
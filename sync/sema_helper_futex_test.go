@@ -0,0 +1,32 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux,!sysv_sema_linux
+
+package sync
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemaDefaultIsFutex(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroySemaphore(testSemaName)) {
+		return
+	}
+	s, err := NewSemaphore(testSemaName, os.O_CREATE|os.O_EXCL, 0666, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer s.Destroy()
+	_, ok := s.semaImpl.(*futexSemaphore)
+	a.True(ok)
+	s2, err := NewSemaphore(testSemaName, SEM_UNDO, 0666, 0)
+	// SEM_UNDO semaphore is a different, sysV object, which does not exist.
+	a.Error(err)
+	if err == nil {
+		s2.Close()
+	}
+}
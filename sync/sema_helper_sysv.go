@@ -0,0 +1,20 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build darwin freebsd linux,sysv_sema_linux
+
+package sync
+
+import "os"
+
+func newDefaultSemaphore(name string, flag int, perm os.FileMode, initial int) (semaImpl, error) {
+	s, err := newSysVSemaphore(name, flag, perm, initial)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// destroySemaphore permanently removes semaphore with the given name.
+func destroySemaphore(name string) error {
+	return destroySysVSemaphore(name)
+}
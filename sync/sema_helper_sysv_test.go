@@ -0,0 +1,28 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build linux,sysv_sema_linux
+
+package sync
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var _ = registerTestBuildTag("sysv_sema_linux")
+
+func TestSemaDefaultIsSysV(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroySemaphore(testSemaName)) {
+		return
+	}
+	s, err := NewSemaphore(testSemaName, os.O_CREATE|os.O_EXCL, 0666, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer s.Destroy()
+	_, ok := s.semaImpl.(*sysvSemaphore)
+	a.True(ok)
+}
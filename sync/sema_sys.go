@@ -32,12 +32,12 @@ func semget(k common.Key, nsems, semflg int) (int, error) {
 	return int(id), nil
 }
 
-func semctl(id, num, cmd int) error {
-	_, _, err := unix.Syscall(sysSemCtl, uintptr(id), uintptr(num), uintptr(cmd))
+func semctl(id, num, cmd int) (int, error) {
+	result, _, err := unix.Syscall(sysSemCtl, uintptr(id), uintptr(num), uintptr(cmd))
 	if err != syscall.Errno(0) {
-		return os.NewSyscallError("SEMCTL", err)
+		return 0, os.NewSyscallError("SEMCTL", err)
 	}
-	return nil
+	return int(result), nil
 }
 
 func semop(id int, ops []sembuf) error {
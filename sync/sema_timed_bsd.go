@@ -28,6 +28,11 @@ import (
 // The code uses the same idea, as used here:
 // https://github.com/attie/libxbee3/blob/master/xsys_darwin/sem_timedwait.c
 
+const (
+	// cSemGetVal is GETVAL semctl command.
+	cSemGetVal = 5
+)
+
 type threadInterrupter struct {
 	state int32
 }
@@ -56,21 +61,20 @@ func (ti *threadInterrupter) done() {
 	atomic.StoreInt32(&ti.state, 1)
 }
 
-func doSemaTimedWait(id int, timeout time.Duration) bool {
+func doSemaTimedWait(id int, b sembuf, timeout time.Duration) (bool, error) {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 	ti := threadInterrupter{}
-	b := sembuf{semnum: 0, semop: int16(-1), semflg: 0}
 	if err := ti.start(timeout); err != nil {
-		panic(errors.Wrap(err, "failed to setup timeout"))
+		return false, errors.Wrap(err, "failed to setup timeout")
 	}
 	err := semop(id, []sembuf{b})
 	ti.done()
 	if err == nil {
-		return true
+		return true, nil
 	}
 	if common.IsInterruptedSyscallErr(err) {
-		return false
+		return false, nil
 	}
-	panic(err)
+	return false, err
 }
@@ -8,16 +8,20 @@ import (
 	"github.com/aybabtme/go-ipc/internal/common"
 )
 
-func doSemaTimedWait(id int, timeout time.Duration) bool {
+const (
+	// cSemGetVal is GETVAL semctl command.
+	cSemGetVal = 12
+)
+
+func doSemaTimedWait(id int, b sembuf, timeout time.Duration) (bool, error) {
 	err := common.UninterruptedSyscallTimeout(func(curTimeout time.Duration) error {
-		b := sembuf{semnum: 0, semop: int16(-1), semflg: 0}
 		return semtimedop(id, []sembuf{b}, common.TimeoutToTimeSpec(curTimeout))
 	}, timeout)
 	if err == nil {
-		return true
+		return true, nil
 	}
 	if common.IsTimeoutErr(err) {
-		return false
+		return false, nil
 	}
-	panic(err)
+	return false, err
 }
@@ -22,20 +22,79 @@ type sembuf struct {
 	semflg int16
 }
 
-// semaphore is a sysV semaphore.
+// semaImpl is a unix semaphore implementation.
+type semaImpl interface {
+	signal(count int) error
+	waitN(n int, timeout time.Duration) (bool, error)
+	value() (int, error)
+	close() error
+	destroy() error
+}
+
+// semaphore is a unix semaphore.
+// It is a sysV semaphore, or a futex-based semaphore on linux, see sema_helper_*.go.
+// Semaphores opened with SEM_UNDO flag, or for an explicit key, are always sysV semaphores.
 type semaphore struct {
-	name string
-	id   int
+	semaImpl
 }
 
-// newSemaphore creates a new sysV semaphore with the given name.
-// It generates a key from the name, and then calls NewSemaphoreKey.
 func newSemaphore(name string, flag int, perm os.FileMode, initial int) (*semaphore, error) {
-	k, err := common.KeyForName(name)
+	var impl semaImpl
+	var err error
+	if flag&SEM_UNDO != 0 {
+		impl, err = newSysVSemaphore(name, flag, perm, initial)
+	} else {
+		impl, err = newDefaultSemaphore(name, flag, perm, initial)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &semaphore{impl}, nil
+}
+
+func (s *semaphore) wait() error {
+	_, err := s.waitN(1, -1)
+	return err
+}
+
+func (s *semaphore) waitTimeout(timeout time.Duration) (bool, error) {
+	return s.waitN(1, timeout)
+}
+
+// newSemaMutexSemaphore returns a semaphore for SemaMutex, which always uses sysV semaphores.
+func newSemaMutexSemaphore(name string, flag int, perm os.FileMode) (*Semaphore, error) {
+	s, err := newSysVSemaphore(name, flag, perm, 1)
+	if err != nil {
+		return nil, err
+	}
+	return (*Semaphore)(&semaphore{s}), nil
+}
+
+// destroySemaMutexSemaphore permanently removes the semaphore of a SemaMutex.
+func destroySemaMutexSemaphore(name string) error {
+	return destroySysVSemaphore(name)
+}
+
+// sysvSemaphore is a sysV semaphore.
+type sysvSemaphore struct {
+	name   string
+	id     int
+	semflg int
+}
+
+// newSysVSemaphore creates a new sysV semaphore with the given name.
+// It generates a key from the name, and then calls NewSemaphoreKey.
+func newSysVSemaphore(name string, flag int, perm os.FileMode, initial int) (*sysvSemaphore, error) {
+	var id int
+	_, created, err := common.OpenOrCreateForName(name, func(k common.Key, create bool) error {
+		var creatorErr error
+		id, creatorErr = semget(k, 1, semgetFlags(perm, create))
+		return creatorErr
+	}, flag)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to generate a key for the name")
+		return nil, errors.Wrap(err, "failed to open/create sysv semaphore")
 	}
-	result, err := newSemaphoreKey(uint64(k), flag, perm, initial)
+	result, err := initSysVSemaphore(id, created, flag, initial)
 	if err != nil {
 		return nil, err
 	}
@@ -43,74 +102,183 @@ func newSemaphore(name string, flag int, perm os.FileMode, initial int) (*semaph
 	return result, nil
 }
 
+// NewSemaphoreKey creates a new System V semaphore for the given key.
+// Unlike NewSemaphore, it does not need to generate a key for a name,
+// which allows cooperating processes to agree on the key explicitly.
+//	key - System V ipc key. It must not be 0 (IPC_PRIVATE).
+//	flag - flag is a combination of open flags from 'os' package along with SEM_UNDO flag.
+//	perm - object's permission bits.
+//	initial - this value will be added to the semaphore's value, if it was created.
+func NewSemaphoreKey(key uint64, flag int, perm os.FileMode, initial int) (*Semaphore, error) {
+	if key == 0 {
+		return nil, errors.New("invalid key")
+	}
+	result, err := newSemaphoreKey(key, flag, perm, initial)
+	if err != nil {
+		return nil, err
+	}
+	return (*Semaphore)(&semaphore{result}), nil
+}
+
 // newSemaphoreKey creates a new sysV semaphore for the given key.
-func newSemaphoreKey(key uint64, flag int, perm os.FileMode, initial int) (*semaphore, error) {
+func newSemaphoreKey(key uint64, flag int, perm os.FileMode, initial int) (*sysvSemaphore, error) {
 	var id int
 	creator := func(create bool) error {
 		var creatorErr error
-		flags := int(perm)
-		if create {
-			flags |= common.IpcCreate | common.IpcExcl
-		}
-		id, creatorErr = semget(common.Key(key), 1, flags)
+		id, creatorErr = semget(common.Key(key), 1, semgetFlags(perm, create))
 		return creatorErr
 	}
 	created, err := common.OpenOrCreate(creator, flag)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to open/create sysv semaphore")
 	}
-	result := &semaphore{id: id}
+	return initSysVSemaphore(id, created, flag, initial)
+}
+
+func semgetFlags(perm os.FileMode, create bool) int {
+	flags := int(perm)
+	if create {
+		flags |= common.IpcCreate | common.IpcExcl
+	}
+	return flags
+}
+
+// initSysVSemaphore sets the initial value of a new semaphore.
+func initSysVSemaphore(id int, created bool, flag int, initial int) (*sysvSemaphore, error) {
+	result := &sysvSemaphore{id: id}
 	if created && initial > 0 {
-		if err = result.add(initial); err != nil {
-			result.Destroy()
+		// the initial value must not be undone, when the creator exits, so it is added without SEM_UNDO.
+		if err := result.add(initial); err != nil {
+			result.destroy()
 			return nil, errors.Wrap(err, "failed to add initial semaphore value")
 		}
 	}
+	if flag&SEM_UNDO != 0 {
+		result.semflg = cSemUndo
+	}
 	return result, nil
 }
 
-func (s *semaphore) signal(count int) {
-	if err := s.add(count); err != nil {
-		panic(err)
-	}
+func (s *sysvSemaphore) signal(count int) error {
+	return s.add(count)
 }
 
-func (s *semaphore) wait() {
-	if err := s.add(-1); err != nil {
-		panic(err)
+// waitN subtracts n from the semaphore value in a single semop call,
+// so that n units are acquired atomically.
+func (s *sysvSemaphore) waitN(n int, timeout time.Duration) (bool, error) {
+	if timeout == 0 {
+		err := common.UninterruptedSyscall(func() error { return semAdd(s.id, -n, s.semflg|common.IpcNoWait) })
+		if err == nil {
+			return true, nil
+		}
+		if common.IsTimeoutErr(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if timeout < 0 {
+		if err := s.add(-n); err != nil {
+			return false, err
+		}
+		return true, nil
 	}
+	return doSemaTimedWait(s.id, sembuf{semop: int16(-n), semflg: int16(s.semflg)}, timeout)
 }
 
-func (s *semaphore) waitTimeout(timeout time.Duration) bool {
-	if timeout < 0 {
-		s.wait()
-		return true
+func (s *sysvSemaphore) value() (int, error) {
+	value, err := semctl(s.id, 0, cSemGetVal)
+	if err != nil {
+		return 0, errors.Wrap(err, "semctl failed")
 	}
-	return doSemaTimedWait(s.id, timeout)
+	return value, nil
 }
 
-func (s *semaphore) close() error {
+func (s *sysvSemaphore) close() error {
 	return nil
 }
 
-func (s *semaphore) Destroy() error {
+func (s *sysvSemaphore) destroy() error {
 	return removeSysVSemaByID(s.id, s.name)
 }
 
-func (s *semaphore) add(value int) error {
-	return common.UninterruptedSyscall(func() error { return semAdd(s.id, value) })
+func (s *sysvSemaphore) add(value int) error {
+	return common.UninterruptedSyscall(func() error { return semAdd(s.id, value, s.semflg) })
+}
+
+// semaUndoMutex is a mutex, which is a binary semaphore with SEM_UNDO flag.
+// If a process dies holding the lock, the system gives the unit back, unlocking the mutex.
+type semaUndoMutex struct {
+	s *sysvSemaphore
+}
+
+func newSemaUndoMutex(s *Semaphore) (semaLocker, error) {
+	sysv, ok := s.semaImpl.(*sysvSemaphore)
+	if !ok {
+		return nil, errors.New("SEM_UNDO mutex requires a sysV semaphore")
+	}
+	return &semaUndoMutex{s: sysv}, nil
+}
+
+func (m *semaUndoMutex) lock() {
+	if err := m.lockErr(); err != nil {
+		panic(err)
+	}
+}
+
+func (m *semaUndoMutex) lockErr() error {
+	_, err := m.s.waitN(1, -1)
+	return err
+}
+
+func (m *semaUndoMutex) tryLock() bool {
+	return m.lockTimeout(0)
+}
+
+func (m *semaUndoMutex) lockTimeout(timeout time.Duration) bool {
+	result, err := m.lockTimeoutErr(timeout)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func (m *semaUndoMutex) lockTimeoutErr(timeout time.Duration) (bool, error) {
+	return m.s.waitN(1, timeout)
+}
+
+func (m *semaUndoMutex) unlock() {
+	if err := m.unlockErr(); err != nil {
+		panic(err)
+	}
 }
 
-// destroySemaphore permanently removes semaphore with the given name.
-func destroySemaphore(name string) error {
+// unlockErr increments the semaphore, only if it is zero. Both operations are done atomically by a single semop call.
+func (m *semaUndoMutex) unlockErr() error {
+	ops := []sembuf{
+		{semop: 0, semflg: common.IpcNoWait},
+		{semop: 1, semflg: int16(m.s.semflg)},
+	}
+	err := common.UninterruptedSyscall(func() error { return semop(m.s.id, ops) })
+	if common.IsTimeoutErr(err) {
+		return ErrNotLocked
+	}
+	return err
+}
+
+// destroySysVSemaphore permanently removes sysV semaphore with the given name.
+func destroySysVSemaphore(name string) error {
 	k, err := common.KeyForName(name)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
 		return errors.Wrap(err, "failed to get a key for the name")
 	}
 	id, err := semget(k, 1, 0)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil
+			// the key file, or the registry entry may have outlived the semaphore.
+			return errors.Wrap(common.ReleaseKey(name), "failed to release the key")
 		}
 		return errors.Wrap(err, "failed to get semaphore id")
 	}
@@ -118,22 +286,17 @@ func destroySemaphore(name string) error {
 }
 
 func removeSysVSemaByID(id int, name string) error {
-	err := semctl(id, 0, common.IpcRmid)
-	if err == nil && len(name) > 0 {
-		if err = os.Remove(common.TmpFilename(name)); os.IsNotExist(err) {
-			err = nil
-		} else if err != nil {
-			err = errors.Wrap(err, "failed to remove temporary file")
-		}
-	} else if os.IsNotExist(err) {
-		err = nil
-	} else {
-		err = errors.Wrap(err, "semctl failed")
+	_, err := semctl(id, 0, common.IpcRmid)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "semctl failed")
 	}
-	return err
+	if len(name) > 0 {
+		return errors.Wrap(common.ReleaseKey(name), "failed to release the key")
+	}
+	return nil
 }
 
-func semAdd(id, value int) error {
-	b := sembuf{semnum: 0, semop: int16(value), semflg: 0}
+func semAdd(id, value, flags int) error {
+	b := sembuf{semnum: 0, semop: int16(value), semflg: int16(flags)}
 	return semop(id, []sembuf{b})
 }
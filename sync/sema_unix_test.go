@@ -0,0 +1,191 @@
+// Copyright 2016 Aleksandr Demakin. All rights reserved.
+
+// +build darwin freebsd linux
+
+package sync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aybabtme/go-ipc/internal/common"
+	testutil "github.com/aybabtme/go-ipc/internal/test"
+	"github.com/aybabtme/go-ipc/namespace"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newSysVTestSemaphore returns a sysV semaphore regardless of the default implementation.
+func newSysVTestSemaphore(name string, flag int, initial int) (*Semaphore, error) {
+	s, err := newSysVSemaphore(name, flag, 0666, initial)
+	if err != nil {
+		return nil, err
+	}
+	return (*Semaphore)(&semaphore{s}), nil
+}
+
+func TestSemaKeyHash(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-ipc-keys")
+	if !a.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	if !a.NoError(namespace.Set(namespace.Namespace{KeyDir: dir, KeyStrategy: namespace.KeyHash})) {
+		return
+	}
+	defer namespace.Reset()
+	if !a.NoError(DestroySemaphore(testSemaName)) {
+		return
+	}
+	s, err := newSysVTestSemaphore(testSemaName, os.O_CREATE|os.O_EXCL, 1)
+	if !a.NoError(err) {
+		return
+	}
+	keys, err := common.RegisteredKeys()
+	a.NoError(err)
+	a.Contains(keys, testSemaName)
+	_, err = os.Stat(filepath.Join(dir, testSemaName))
+	a.True(os.IsNotExist(err))
+	s2, err := newSysVTestSemaphore(testSemaName, 0, 0)
+	if a.NoError(err) {
+		a.True(s2.WaitTimeout(0))
+		a.NoError(s2.Close())
+	}
+	a.NoError(s.Close())
+	a.NoError(DestroySemaphore(testSemaName))
+	keys, err = common.RegisteredKeys()
+	a.NoError(err)
+	a.NotContains(keys, testSemaName)
+}
+
+func TestSemaKey(t *testing.T) {
+	const key = 0x1eed0043
+	a := assert.New(t)
+	s, err := NewSemaphoreKey(key, os.O_CREATE|os.O_EXCL, 0666, 1)
+	if !a.NoError(err) {
+		return
+	}
+	defer s.Destroy()
+	s2, err := NewSemaphoreKey(key, 0, 0666, 0)
+	if a.NoError(err) {
+		a.True(s2.WaitTimeout(0))
+		a.False(s.WaitTimeout(time.Millisecond * 10))
+	}
+	_, err = NewSemaphoreKey(0, os.O_CREATE, 0666, 0)
+	a.Error(err)
+}
+
+func TestSemaDestroyRemovesKeyFile(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-ipc-keys")
+	if !a.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	if !a.NoError(namespace.Set(namespace.Namespace{KeyDir: dir})) {
+		return
+	}
+	defer namespace.Reset()
+	s, err := newSysVTestSemaphore(testSemaName, os.O_CREATE|os.O_EXCL, 0)
+	if !a.NoError(err) {
+		return
+	}
+	_, err = os.Stat(filepath.Join(dir, testSemaName))
+	a.NoError(err)
+	a.NoError(s.Close())
+	a.NoError(DestroySemaphore(testSemaName))
+	_, err = os.Stat(filepath.Join(dir, testSemaName))
+	a.True(os.IsNotExist(err))
+	a.NoError(DestroySemaphore(testSemaName))
+	_, err = os.Stat(filepath.Join(dir, testSemaName))
+	a.True(os.IsNotExist(err))
+}
+
+func TestSemaWaitNIsAtomic(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroySemaphore(testSemaName)) {
+		return
+	}
+	s, err := NewSemaphore(testSemaName, os.O_CREATE|os.O_EXCL, 0666, 1)
+	if !a.NoError(err) {
+		return
+	}
+	defer s.Destroy()
+	ch := make(chan bool, 1)
+	go func() {
+		ch <- s.WaitN(2, time.Millisecond*200)
+	}()
+	time.Sleep(time.Millisecond * 50)
+	// the waiter must not hold the only available unit.
+	a.Equal(1, s.Value())
+	a.True(s.TryWait())
+	a.False(<-ch)
+	a.Equal(0, s.Value())
+}
+
+func TestSemaUndo(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroySemaphore(testSemaName)) {
+		return
+	}
+	s, err := NewSemaphore(testSemaName, os.O_CREATE|os.O_EXCL|SEM_UNDO, 0666, 1)
+	if !a.NoError(err) {
+		return
+	}
+	defer s.Destroy()
+	// the initial value must not be undone.
+	a.Equal(1, s.Value())
+	a.True(s.WaitN(1, 0))
+	a.Equal(0, s.Value())
+	s.Signal(3)
+	a.Equal(3, s.Value())
+}
+
+func TestSemaUndoKilledProcess(t *testing.T) {
+	testSemaKilledProcess(t, true)
+}
+
+func TestSemaNoUndoKilledProcess(t *testing.T) {
+	testSemaKilledProcess(t, false)
+}
+
+func testSemaKilledProcess(t *testing.T, undo bool) {
+	a := assert.New(t)
+	if !a.NoError(DestroySemaphore(testSemaName)) {
+		return
+	}
+	flag := os.O_CREATE | os.O_EXCL
+	if undo {
+		// the child process opens a sysV semaphore, if SEM_UNDO is set.
+		flag |= SEM_UNDO
+	}
+	s, err := NewSemaphore(testSemaName, flag, 0666, 3)
+	if !a.NoError(err) {
+		return
+	}
+	defer s.Destroy()
+	killCh := make(chan bool, 1)
+	resultCh := testutil.RunTestAppAsync(argsForSemaAcquireCommand(testSemaName, 2, undo), killCh)
+	deadline := time.Now().Add(time.Second * 10)
+	for s.Value() != 1 {
+		if time.Now().After(deadline) {
+			killCh <- true
+			t.Errorf("the units were not acquired")
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	killCh <- true
+	if _, ok := testutil.WaitForAppResultChan(resultCh, time.Second*5); !a.True(ok) {
+		return
+	}
+	if undo {
+		a.Equal(3, s.Value())
+	} else {
+		a.Equal(1, s.Value())
+	}
+}
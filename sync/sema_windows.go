@@ -11,6 +11,10 @@ import (
 	"golang.org/x/sys/windows"
 )
 
+var (
+	errSemUndoNotSupported = errors.New("SEM_UNDO is not supported on windows")
+)
+
 // semaphore is a platform specific semaphore implementation.
 // on windows it uses system semaphore object.
 type semaphore struct {
@@ -18,6 +22,9 @@ type semaphore struct {
 }
 
 func newSemaphore(name string, flag int, perm os.FileMode, initial int) (*semaphore, error) {
+	if flag&SEM_UNDO != 0 {
+		return nil, errSemUndoNotSupported
+	}
 	if err := ensureOpenFlags(flag); err != nil {
 		return nil, err
 	}
@@ -35,17 +42,17 @@ func (s *semaphore) close() error {
 	return nil
 }
 
-func (s *semaphore) signal(count int) {
-	if _, err := sys_ReleaseSemaphore(s.handle, count); err != nil {
-		panic(err)
-	}
+func (s *semaphore) signal(count int) error {
+	_, err := sys_ReleaseSemaphore(s.handle, count)
+	return err
 }
 
-func (s *semaphore) wait() {
-	s.waitTimeout(-1)
+func (s *semaphore) wait() error {
+	_, err := s.waitTimeout(-1)
+	return err
 }
 
-func (s *semaphore) waitTimeout(timeout time.Duration) bool {
+func (s *semaphore) waitTimeout(timeout time.Duration) (bool, error) {
 	waitMillis := uint32(windows.INFINITE)
 	if timeout >= 0 {
 		waitMillis = uint32(timeout.Nanoseconds() / 1e6)
@@ -53,16 +60,61 @@ func (s *semaphore) waitTimeout(timeout time.Duration) bool {
 	ev, err := windows.WaitForSingleObject(s.handle, waitMillis)
 	switch ev {
 	case windows.WAIT_OBJECT_0:
-		return true
+		return true, nil
 	case uint32(windows.WAIT_TIMEOUT):
-		return false
+		return false, nil
 	default:
 		if err != nil {
-			panic(err)
-		} else {
-			panic(errors.Errorf("invalid wait state for an event: %d", ev))
+			return false, err
+		}
+		return false, errors.Errorf("invalid wait state for a semaphore: %d", ev)
+	}
+}
+
+// waitN acquires n units one by one, as windows can't do it atomically.
+// If it fails to acquire all of them, the acquired units are released back.
+func (s *semaphore) waitN(n int, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for acquired := 0; acquired < n; acquired++ {
+		curTimeout := timeout
+		if timeout > 0 {
+			if curTimeout = time.Until(deadline); curTimeout < 0 {
+				curTimeout = 0
+			}
+		}
+		ok, err := s.waitTimeout(curTimeout)
+		if ok && err == nil {
+			continue
+		}
+		if acquired > 0 {
+			if signalErr := s.signal(acquired); signalErr != nil && err == nil {
+				err = errors.Wrap(signalErr, "failed to release acquired units")
+			}
 		}
+		return false, err
 	}
+	return true, nil
+}
+
+func (s *semaphore) value() (int, error) {
+	return sys_NtQuerySemaphore(s.handle)
+}
+
+// destroy closes the handle. The semaphore is removed by the system, when its last handle is closed.
+func (s *semaphore) destroy() error {
+	return s.close()
+}
+
+func newSemaUndoMutex(s *Semaphore) (semaLocker, error) {
+	return nil, errSemUndoNotSupported
+}
+
+func newSemaMutexSemaphore(name string, flag int, perm os.FileMode) (*Semaphore, error) {
+	return NewSemaphore(name, flag, perm, 1)
+}
+
+func destroySemaMutexSemaphore(name string) error {
+	return destroySemaphore(name)
 }
 
 // destroySemaphore is a no-op on windows.
@@ -7,24 +7,37 @@ import (
 	"time"
 
 	"github.com/aybabtme/go-ipc/internal/common"
+
+	"github.com/pkg/errors"
 )
 
 const (
 	// CSemMaxVal is the maximum semaphore value,
 	// which is guaranteed to be supported on all platforms.
 	CSemMaxVal = 32767
+
+	// SEM_UNDO flag makes the system undo semaphore operations of a process, when the process exits.
+	// This way units, acquired by a crashed process, are given back to the semaphore.
+	// As the releases are undone too, units should be released by the process, which acquired them.
+	// The initial value is not affected by the flag.
+	// It is supported on unix only, NewSemaphore fails on windows, if the flag is set.
+	SEM_UNDO = 0x40000000
 )
 
 // Semaphore is a synchronization object with a resource counter,
 // which can be used to control access to a shared resource.
 // It provides access to actual OS semaphore primitive via:
 //	CreateSemaprore on windows
-//	semget on unix
+//	semget on darwin and freebsd
+//	futex on linux. System V semaphores can be selected with 'sysv_sema_linux' build tag.
+// Semaphores, opened with SEM_UNDO flag or with NewSemaphoreKey, are always System V semaphores on unix.
+// On linux such a semaphore is a different object, than a futex-based semaphore with the same name,
+// so all processes must open it with the same SEM_UNDO setting.
 type Semaphore semaphore
 
 // NewSemaphore creates new semaphore with the given name.
 //	name - object name.
-//	flag - flag is a combination of open flags from 'os' package.
+//	flag - flag is a combination of open flags from 'os' package along with SEM_UNDO flag.
 //	perm - object's permission bits.
 //	initial - this value will be added to the semaphore's value, if it was created.
 func NewSemaphore(name string, flag int, perm os.FileMode, initial int) (*Semaphore, error) {
@@ -36,13 +49,31 @@ func NewSemaphore(name string, flag int, perm os.FileMode, initial int) (*Semaph
 }
 
 // Signal increments the value of semaphore variable by 1, waking waiting process (if any).
+// It panics on an error.
 func (s *Semaphore) Signal(count int) {
-	(*semaphore)(s).signal(count)
+	if err := s.SignalErr(count); err != nil {
+		panic(err)
+	}
+}
+
+// SignalErr increments the value of semaphore variable by 1, waking waiting process (if any).
+// It returns an error, if the operation failed.
+func (s *Semaphore) SignalErr(count int) error {
+	return (*semaphore)(s).signal(count)
 }
 
 // Wait decrements the value of semaphore variable by -1, and blocks if the value becomes negative.
+// It panics on an error.
 func (s *Semaphore) Wait() {
-	(*semaphore)(s).wait()
+	if err := s.WaitErr(); err != nil {
+		panic(err)
+	}
+}
+
+// WaitErr decrements the value of semaphore variable by -1, and blocks if the value becomes negative.
+// It returns an error, if the operation failed.
+func (s *Semaphore) WaitErr() error {
+	return (*semaphore)(s).wait()
 }
 
 // Close closes the semaphore.
@@ -53,10 +84,82 @@ func (s *Semaphore) Close() error {
 // WaitTimeout decrements the value of semaphore variable by 1.
 // If the value becomes negative, it waites for not longer than timeout.
 // On darwin and freebsd this func has some side effects, see sema_timed_bsd.go for details.
+// It panics on an error.
 func (s *Semaphore) WaitTimeout(timeout time.Duration) bool {
+	result, err := s.WaitTimeoutErr(timeout)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// WaitTimeoutErr is the same as WaitTimeout, but it returns an error instead of panicking.
+// It returns false and a nil error, if the timeout has elapsed.
+func (s *Semaphore) WaitTimeoutErr(timeout time.Duration) (bool, error) {
 	return (*semaphore)(s).waitTimeout(timeout)
 }
 
+// TryWait decrements the value of semaphore variable by 1, if it is positive.
+// It returns false if the value is 0, without blocking.
+// It panics on an error.
+func (s *Semaphore) TryWait() bool {
+	result, err := s.TryWaitErr()
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// TryWaitErr is the same as TryWait, but it returns an error instead of panicking.
+func (s *Semaphore) TryWaitErr() (bool, error) {
+	return (*semaphore)(s).waitN(1, 0)
+}
+
+// WaitN decrements the value of semaphore variable by n.
+// If the value is less than n, it waits for not longer than timeout for the value to become large enough.
+// If timeout is negative, it waits infinitely.
+// On unix, n units are acquired atomically, so a waiter never holds a part of them.
+// On windows, units are acquired one by one, and all of them are released back, if the timeout elapses.
+// It panics on an error.
+func (s *Semaphore) WaitN(n int, timeout time.Duration) bool {
+	result, err := s.WaitNErr(n, timeout)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// WaitNErr is the same as WaitN, but it returns an error instead of panicking.
+// It returns false and a nil error, if the timeout has elapsed.
+func (s *Semaphore) WaitNErr(n int, timeout time.Duration) (bool, error) {
+	if n <= 0 || n > CSemMaxVal {
+		return false, errors.Errorf("invalid semaphore count %d", n)
+	}
+	return (*semaphore)(s).waitN(n, timeout)
+}
+
+// Value returns current value of the semaphore.
+// The value may be changed by other processes right after it was read,
+// so it should be used for diagnostics and statistics only.
+// It panics on an error.
+func (s *Semaphore) Value() int {
+	result, err := s.ValueErr()
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// ValueErr is the same as Value, but it returns an error instead of panicking.
+func (s *Semaphore) ValueErr() (int, error) {
+	return (*semaphore)(s).value()
+}
+
+// Destroy closes the semaphore and removes it permanently.
+func (s *Semaphore) Destroy() error {
+	return (*semaphore)(s).destroy()
+}
+
 // DestroySemaphore removes the semaphore permanently.
 func DestroySemaphore(name string) error {
 	return destroySemaphore(name)
@@ -71,12 +174,18 @@ func newSemaWaiter(s *Semaphore) *semaWaiter {
 }
 
 func (sw *semaWaiter) wake(count int32) (int, error) {
-	sw.s.Signal(int(count))
+	if err := sw.s.SignalErr(int(count)); err != nil {
+		return 0, err
+	}
 	return int(count), nil
 }
 
 func (sw *semaWaiter) wait(unused int32, timeout time.Duration) error {
-	if !sw.s.WaitTimeout(timeout) {
+	ok, err := sw.s.WaitTimeoutErr(timeout)
+	if err != nil {
+		return err
+	}
+	if !ok {
 		return common.NewTimeoutError("SEMWAWIT")
 	}
 	return nil
@@ -203,6 +203,122 @@ func TestTimedSema(t *testing.T) {
 	a.False(s.WaitTimeout(time.Millisecond * 50))
 }
 
+func TestSemaErr(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroySemaphore(testSemaName)) {
+		return
+	}
+	s, err := NewSemaphore(testSemaName, os.O_CREATE|os.O_EXCL, 0666, 0)
+	if !a.NoError(err) {
+		return
+	}
+	defer func(s *Semaphore) {
+		a.NoError(s.Close())
+		a.NoError(DestroySemaphore(testSemaName))
+	}(s)
+	a.NoError(s.SignalErr(2))
+	a.NoError(s.WaitErr())
+	ok, err := s.WaitTimeoutErr(time.Millisecond * 50)
+	a.NoError(err)
+	a.True(ok)
+	ok, err = s.WaitTimeoutErr(time.Millisecond * 50)
+	a.NoError(err)
+	a.False(ok)
+}
+
+func TestSemaTryWait(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroySemaphore(testSemaName)) {
+		return
+	}
+	s, err := NewSemaphore(testSemaName, os.O_CREATE|os.O_EXCL, 0666, 2)
+	if !a.NoError(err) {
+		return
+	}
+	defer func(s *Semaphore) {
+		a.NoError(s.Destroy())
+	}(s)
+	a.True(s.TryWait())
+	a.True(s.TryWait())
+	a.False(s.TryWait())
+	s.Signal(1)
+	ok, err := s.TryWaitErr()
+	a.NoError(err)
+	a.True(ok)
+}
+
+func TestSemaValue(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroySemaphore(testSemaName)) {
+		return
+	}
+	s, err := NewSemaphore(testSemaName, os.O_CREATE|os.O_EXCL, 0666, 3)
+	if !a.NoError(err) {
+		return
+	}
+	defer func(s *Semaphore) {
+		a.NoError(s.Destroy())
+	}(s)
+	a.Equal(3, s.Value())
+	s.Wait()
+	a.Equal(2, s.Value())
+	s.Signal(5)
+	value, err := s.ValueErr()
+	a.NoError(err)
+	a.Equal(7, value)
+}
+
+func TestSemaWaitN(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroySemaphore(testSemaName)) {
+		return
+	}
+	s, err := NewSemaphore(testSemaName, os.O_CREATE|os.O_EXCL, 0666, 5)
+	if !a.NoError(err) {
+		return
+	}
+	defer func(s *Semaphore) {
+		a.NoError(s.Destroy())
+	}(s)
+	a.True(s.WaitN(3, 0))
+	a.False(s.WaitN(3, 0))
+	a.False(s.WaitN(3, time.Millisecond*50))
+	a.Equal(2, s.Value())
+	ch := make(chan bool, 1)
+	go func() {
+		ch <- s.WaitN(4, -1)
+	}()
+	time.Sleep(time.Millisecond * 50)
+	s.Signal(2)
+	select {
+	case ok := <-ch:
+		a.True(ok)
+	case <-time.After(time.Second * 3):
+		t.Errorf("timeout")
+	}
+	a.Equal(0, s.Value())
+	_, err = s.WaitNErr(0, 0)
+	a.Error(err)
+	_, err = s.WaitNErr(-1, 0)
+	a.Error(err)
+}
+
+func TestSemaDestroy(t *testing.T) {
+	a := assert.New(t)
+	if !a.NoError(DestroySemaphore(testSemaName)) {
+		return
+	}
+	s, err := NewSemaphore(testSemaName, os.O_CREATE|os.O_EXCL, 0666, 0)
+	if !a.NoError(err) {
+		return
+	}
+	a.NoError(s.Destroy())
+	s, err = NewSemaphore(testSemaName, os.O_CREATE|os.O_EXCL, 0666, 0)
+	if a.NoError(err) {
+		a.NoError(s.Destroy())
+	}
+}
+
 func TestSemaSignalAnotherProcess(t *testing.T) {
 	a := assert.New(t)
 	if !a.NoError(DestroySemaphore(testSemaName)) {
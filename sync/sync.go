@@ -6,6 +6,8 @@ import (
 	"os"
 	"time"
 
+	"github.com/aybabtme/go-ipc/internal/allocator"
+
 	"github.com/pkg/errors"
 )
 
@@ -17,6 +19,18 @@ func ensureOpenFlags(flags int) error {
 	return nil
 }
 
+// checkPlacement ensures, that the memory is large enough and properly aligned
+// to hold the state of a primitive of the given size.
+func checkPlacement(mem []byte, size int, align uintptr) error {
+	if len(mem) < size {
+		return errors.Errorf("the memory must be at least %d bytes long", size)
+	}
+	if uintptr(allocator.ByteSliceData(mem))%align != 0 {
+		return errors.Errorf("the memory must be %d-byte aligned", align)
+	}
+	return nil
+}
+
 // waitWaker is an object, which implements wake/wait semantics.
 type waitWaker interface {
 	wake(count int32) (int, error)
@@ -11,6 +11,7 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 
 	testutil "github.com/aybabtme/go-ipc/internal/test"
 	"github.com/aybabtme/go-ipc/mmf"
@@ -31,6 +32,8 @@ var (
 	eventProgArgs    []string
 	semaProgArgs     []string
 	defaultMutexType = "m"
+	// testBuildTags are passed to the test programs, so that they use the same implementations.
+	testBuildTags []string
 )
 
 func locate(path string) []string {
@@ -44,12 +47,22 @@ func locate(path string) []string {
 	for i, name := range files {
 		files[i] = path + name
 	}
+	tags := testBuildTags
 	if defaultMutexType == "msysv" {
-		files = append([]string{`-tags="sysv_mutex_linux"`}, files...)
+		tags = append(tags, "sysv_mutex_linux")
+	}
+	if len(tags) > 0 {
+		files = append([]string{"-tags=" + strings.Join(tags, ",")}, files...)
 	}
 	return files
 }
 
+// registerTestBuildTag adds a build tag, which must be passed to the test programs.
+func registerTestBuildTag(tag string) string {
+	testBuildTags = append(testBuildTags, tag)
+	return tag
+}
+
 func detectMutexType() {
 	DestroyMutex(testLockerName)
 	m, err := NewMutex(testLockerName, os.O_CREATE, 0666)
@@ -100,6 +113,15 @@ func argsForSyncDestroyCommand(name string) []string {
 	return append(lockerProgArgs, "-object="+name, "destroy")
 }
 
+func argsForSyncLockCommand(name, t string, undo bool) []string {
+	return append(lockerProgArgs,
+		"-object="+name,
+		"-type="+t,
+		"-undo="+strconv.FormatBool(undo),
+		"lock",
+	)
+}
+
 func argsForSyncInc64Command(name, t string, jobs int, shmName string, n int) []string {
 	return append(lockerProgArgs,
 		"-object="+name,
@@ -185,6 +207,15 @@ func argsForSemaWaitCommand(name string, timeoutMS int) []string {
 	)
 }
 
+func argsForSemaAcquireCommand(name string, count int, undo bool) []string {
+	return append(semaProgArgs,
+		"-undo="+strconv.FormatBool(undo),
+		"acquire",
+		name,
+		strconv.Itoa(count),
+	)
+}
+
 func startPprof() {
 	go func() {
 		fmt.Println(http.ListenAndServe("localhost:6060", nil))
@@ -41,12 +41,12 @@ func semget(k common.Key, nsems, semflg int) (int, error) {
 	return int(id), nil
 }
 
-func semctl(id, num, cmd int) error {
-	_, _, err := unix.Syscall6(unix.SYS_IPC, cSEMCTL, uintptr(id), uintptr(num), uintptr(cmd), uintptr(semun_inst), 0)
+func semctl(id, num, cmd int) (int, error) {
+	result, _, err := unix.Syscall6(unix.SYS_IPC, cSEMCTL, uintptr(id), uintptr(num), uintptr(cmd), uintptr(semun_inst), 0)
 	if err != syscall.Errno(0) {
-		return os.NewSyscallError("SEMCTL", err)
+		return 0, os.NewSyscallError("SEMCTL", err)
 	}
-	return nil
+	return int(result), nil
 }
 
 func semop(id int, ops []sembuf) error {
@@ -9,12 +9,15 @@ import (
 
 	"github.com/aybabtme/go-ipc/internal/allocator"
 	"github.com/aybabtme/go-ipc/internal/common"
+	"github.com/aybabtme/go-ipc/namespace"
+	"github.com/pkg/errors"
 	"golang.org/x/sys/windows"
 )
 
 const (
 	cEVENT_MODIFY_STATE     = 0x0002
 	cSEMAPHORE_MODIFY_STATE = 0x0002
+	cSEMAPHORE_QUERY_STATE  = 0x0001
 )
 
 var (
@@ -24,8 +27,17 @@ var (
 	procCreateSemaphore  = modkernel32.NewProc("CreateSemaphoreW")
 	procOpenSemaphore    = modkernel32.NewProc("OpenSemaphoreW")
 	procReleaseSemaphore = modkernel32.NewProc("ReleaseSemaphore")
+
+	modntdll             = windows.NewLazyDLL("ntdll.dll")
+	procNtQuerySemaphore = modntdll.NewProc("NtQuerySemaphore")
 )
 
+// semaphoreBasicInformation is SEMAPHORE_BASIC_INFORMATION struct.
+type semaphoreBasicInformation struct {
+	currentCount int32
+	maximumCount int32
+}
+
 func sys_OpenEvent(name string, desiredAccess uint32, inheritHandle uint32) (windows.Handle, error) {
 	namep, err := windows.UTF16PtrFromString(name)
 	if err != nil {
@@ -66,6 +78,7 @@ func sys_CreateEvent(name string, eventAttrs *windows.SecurityAttributes, manual
 }
 
 func openOrCreateEvent(name string, flag int, initial int) (windows.Handle, error) {
+	name = namespace.Name(name)
 	var handle windows.Handle
 	creator := func(create bool) error {
 		var err error
@@ -127,6 +140,23 @@ func sys_ReleaseSemaphore(h windows.Handle, count int) (int, error) {
 	return int(prev), err
 }
 
+func sys_NtQuerySemaphore(h windows.Handle) (int, error) {
+	var info semaphoreBasicInformation
+	infoPtr := unsafe.Pointer(&info)
+	status, _, _ := procNtQuerySemaphore.Call(
+		uintptr(h),
+		0, // SemaphoreBasicInformation
+		uintptr(infoPtr),
+		unsafe.Sizeof(info),
+		0,
+	)
+	allocator.Use(infoPtr)
+	if status != 0 {
+		return 0, errors.Errorf("NtQuerySemaphore failed with status 0x%x", status)
+	}
+	return int(info.currentCount), nil
+}
+
 func sys_OpenSemaphore(name string, desiredAccess uint32, inheritHandle uint32) (windows.Handle, error) {
 	namep, err := windows.UTF16PtrFromString(name)
 	if err != nil {
@@ -144,6 +174,7 @@ func sys_OpenSemaphore(name string, desiredAccess uint32, inheritHandle uint32)
 }
 
 func openOrCreateSemaphore(name string, flag int, initial, maximum int) (windows.Handle, error) {
+	name = namespace.Name(name)
 	var handle windows.Handle
 	creator := func(create bool) error {
 		var err error
@@ -154,7 +185,7 @@ func openOrCreateSemaphore(name string, flag int, initial, maximum int) (windows
 				return err
 			}
 		} else {
-			handle, err = sys_OpenSemaphore(name, windows.SYNCHRONIZE|cSEMAPHORE_MODIFY_STATE, 0)
+			handle, err = sys_OpenSemaphore(name, windows.SYNCHRONIZE|cSEMAPHORE_MODIFY_STATE|cSEMAPHORE_QUERY_STATE, 0)
 		}
 		if handle != windows.Handle(0) {
 			return nil